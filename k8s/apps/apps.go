@@ -21,6 +21,11 @@ var (
 	deleteForegroundPolicy = metav1.DeletePropagationForeground
 )
 
+const (
+	// pvcValidateConcurrency is the max number of PVCs validated in parallel
+	pvcValidateConcurrency = 10
+)
+
 // Ops is an interface to perform kubernetes related operations on the apps resources.
 type Ops interface {
 	DaemonSetOps
@@ -156,7 +156,7 @@ func (c *Client) ValidateDaemonSet(name, namespace string, timeout time.Duration
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second, task.WithOperation("apps.ValidateDaemonSet")); err != nil {
 		return err
 	}
 	return nil
@@ -206,7 +206,7 @@ func (c *Client) ValidateDaemonSetIsTerminated(name, namespace string, timeout t
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second, task.WithOperation("apps.ValidateDaemonSetIsTerminated")); err != nil {
 		return err
 	}
 	return nil
@@ -195,7 +195,7 @@ func (c *Client) ValidateDeployment(deployment *appsv1.Deployment, timeout, retr
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("apps.ValidateDeployment")); err != nil {
 		return err
 	}
 	return nil
@@ -242,7 +242,7 @@ func (c *Client) ValidateTerminatedDeployment(deployment *appsv1.Deployment, tim
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, timeBeforeRetry); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, timeBeforeRetry, task.WithOperation("apps.ValidateTerminatedDeployment")); err != nil {
 		return err
 	}
 	return nil
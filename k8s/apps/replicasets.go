@@ -158,7 +158,7 @@ func (c *Client) ValidateReplicaSet(name, namespace string, timeout time.Duratio
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, 15*time.Second, task.WithOperation("apps.ValidateReplicaSet")); err != nil {
 		return err
 	}
 	return nil
@@ -160,7 +160,7 @@ func (c *Client) ValidateStatefulSet(statefulset *appsv1.StatefulSet, timeout ti
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, 10*time.Second, task.WithOperation("apps.ValidateStatefulSet")); err != nil {
 		return err
 	}
 	return nil
@@ -209,7 +209,7 @@ func (c *Client) ValidateTerminatedStatefulSet(statefulset *appsv1.StatefulSet,
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, timeBeforeRetry); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, timeBeforeRetry, task.WithOperation("apps.ValidateTerminatedStatefulSet")); err != nil {
 		return err
 	}
 	return nil
@@ -265,6 +265,8 @@ func (c *Client) ValidatePVCsForStatefulSet(ss *appsv1.StatefulSet, timeout, ret
 		return err
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 	t := func() (interface{}, bool, error) {
 		pvcList, err := c.core.PersistentVolumeClaims(ss.Namespace).List(context.TODO(), listOptions)
 		if err != nil {
@@ -276,16 +278,17 @@ func (c *Client) ValidatePVCsForStatefulSet(ss *appsv1.StatefulSet, timeout, ret
 			return nil, true, fmt.Errorf("Expected PVCs: %v, Actual: %v", expectedPVCCount, len(pvcList.Items))
 		}
 
-		for _, pvc := range pvcList.Items {
-			if err := c.validatePersistentVolumeClaim(&pvc, timeout, retryTimeout); err != nil {
-				return nil, true, err
-			}
+		_, err = task.RunParallel(ctx, pvcList.Items, pvcValidateConcurrency, func(ctx context.Context, pvc corev1.PersistentVolumeClaim) (interface{}, error) {
+			return nil, c.validatePersistentVolumeClaim(ctx, &pvc, retryTimeout)
+		})
+		if err != nil {
+			return nil, true, err
 		}
 
 		return nil, false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryTimeout); err != nil {
+	if _, err := task.DoRetryWithContext(ctx, t, task.ConstantBackoff(retryTimeout), task.WithOperation("apps.ValidatePVCsForStatefulSet")); err != nil {
 		return err
 	}
 	return nil
@@ -305,13 +308,13 @@ func (c *Client) getListOptionsForStatefulSet(ss *appsv1.StatefulSet) (metav1.Li
 }
 
 // validatePersistentVolumeClaim is a copy of core.ValidatePersistentVolumeClaim.
-func (c *Client) validatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim, timeout, retryInterval time.Duration) error {
+func (c *Client) validatePersistentVolumeClaim(ctx context.Context, pvc *corev1.PersistentVolumeClaim, retryInterval time.Duration) error {
 	t := func() (interface{}, bool, error) {
 		if err := c.initClient(); err != nil {
 			return "", true, err
 		}
 
-		result, err := c.core.PersistentVolumeClaims(pvc.Namespace).Get(context.TODO(), pvc.Name, metav1.GetOptions{})
+		result, err := c.core.PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
 		if err != nil {
 			return "", true, err
 		}
@@ -326,7 +329,7 @@ func (c *Client) validatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithContext(ctx, t, task.ConstantBackoff(retryInterval), task.WithOperation("apps.validatePersistentVolumeClaim")); err != nil {
 		return err
 	}
 	return nil
@@ -93,7 +93,7 @@ func (c *Client) ValidateJob(name, namespace string, timeout time.Duration) erro
 		return nil, false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, 10*time.Second, task.WithOperation("batch.ValidateJob")); err != nil {
 		return err
 	}
 
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	schederrors "github.com/portworx/sched-ops/k8s/errors"
@@ -68,40 +67,37 @@ func DeletePods(client v1.CoreV1Interface, pods []corev1.Pod, force bool) error
 	return nil
 }
 
-// WaitForPodsToBeDeleted waits for pods to be deleted
-func WaitForPodsToBeDeleted(client v1.CoreV1Interface, podsToDelete []corev1.Pod, timeout time.Duration) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error)
-
-	// Wait for pods to be deleted
-	for index, pod := range podsToDelete {
-		wg.Add(1)
-		go func(pod corev1.Pod, index int) {
-			defer wg.Done()
-			if err := WaitForPodDeletion(client, pod, timeout); err != nil {
-				errChan <- fmt.Errorf("Failed to delete pod %s, Err: %v", pod.Name, err)
-			}
-		}(pod, index)
-
-	}
-	wg.Wait()
-	close(errChan)
+// podDeleteWaitConcurrency is the max number of pods waited on in parallel by WaitForPodsToBeDeleted
+const podDeleteWaitConcurrency = 10
 
-	ok := true
-	var errorString string
-	for err := range errChan {
-		errorString += fmt.Sprintf("%v\n", err)
-		ok = false
-	}
+// WaitForPodsToBeDeleted waits for pods to be deleted. The pods are waited on in parallel, for at
+// most timeout overall.
+func WaitForPodsToBeDeleted(client v1.CoreV1Interface, podsToDelete []corev1.Pod, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	if !ok {
-		return fmt.Errorf("Failed to delete pods:\n%s", errorString)
+	_, err := task.RunParallel(ctx, podsToDelete, podDeleteWaitConcurrency, func(ctx context.Context, pod corev1.Pod) (interface{}, error) {
+		if err := waitForPodDeletion(ctx, client, pod); err != nil {
+			return nil, fmt.Errorf("Failed to delete pod %s, Err: %v", pod.Name, err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to delete pods: %w", err)
 	}
 	return nil
 }
 
 // WaitForPodDeletion waits for given timeout for given pod to be deleted
 func WaitForPodDeletion(client v1.CoreV1Interface, pod corev1.Pod, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return waitForPodDeletion(ctx, client, pod)
+}
+
+// waitForPodDeletion waits for the given pod to be deleted until ctx is done
+func waitForPodDeletion(ctx context.Context, client v1.CoreV1Interface, pod corev1.Pod) error {
 	t := func() (interface{}, bool, error) {
 		p, err := GetPodByName(client, pod.Name, pod.Namespace)
 		if err != nil {
@@ -119,7 +115,7 @@ func WaitForPodDeletion(client v1.CoreV1Interface, pod corev1.Pod, timeout time.
 		return nil, false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+	if _, err := task.DoRetryWithContext(ctx, t, task.ConstantBackoff(5*time.Second), task.WithOperation("common.WaitForPodDeletion")); err != nil {
 		return err
 	}
 
@@ -0,0 +1,424 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// podsByPVCIndex indexes the pods by the namespace/name of the PVCs they use
+	podsByPVCIndex = "pvc"
+	// podsByNodeIndex indexes the pods by the name of the node they are scheduled on
+	podsByNodeIndex = "node"
+	// pvcsByStorageClassIndex indexes the PVCs by the name of their storage class
+	pvcsByStorageClassIndex = "storageClass"
+	// defaultCacheMaxStaleness is the default CacheOptions.MaxStaleness
+	defaultCacheMaxStaleness = 30 * time.Second
+)
+
+// CacheOptions are the options of the cache enabled by EnableCache
+type CacheOptions struct {
+	// ResyncPeriod is the resync period of the informers. Zero disables the resync.
+	ResyncPeriod time.Duration
+	// MaxStaleness is how long cached reads are still served after the watch of the API server failed.
+	// Past it, reads go to the API server until the watch recovers. It defaults to 30 seconds.
+	MaxStaleness time.Duration
+	// Namespace restricts the cached pods and PVCs to the namespace. Reads of other namespaces, or of
+	// all the namespaces, go to the API server. Empty caches all the namespaces.
+	Namespace string
+	// PodFieldSelector restricts the cached pods, e.g. spec.nodeName=<node> on a node agent so that it
+	// does not cache every pod of the cluster. Only the pod reads with the same field selector, like
+	// GetPodsByNode or GetPodsUsingPVCByNodeName of that node, are then served from the cache.
+	PodFieldSelector string
+}
+
+// clientCache holds the informers used for the cached reads of a client.
+type clientCache struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	maxStaleness time.Duration
+	// namespace is the namespace of the cached objects, empty for all the namespaces
+	namespace string
+	// podFieldSelector is the field selector of the cached pods, empty for all the pods
+	podFieldSelector string
+	pods             *cachedInformer
+	pvcs             *cachedInformer
+}
+
+// cachedInformer is an informer which keeps track of the failures of its watch.
+type cachedInformer struct {
+	informer cache.SharedIndexInformer
+
+	mu sync.Mutex
+	// watchFailedAt is the time the watch failed, zero if it is working
+	watchFailedAt time.Time
+}
+
+// EnableCache serves the pod and PVC reads of the hot paths of the client, like GetPodsUsingPVC,
+// GetPodsByNode, GetPodsUsingVolumePlugin, GetPersistentVolumeClaim and GetPVCsUsingStorageClass,
+// from shared informers instead of the API server. It returns once the informers are synced. The
+// cache is disabled when ctx is done. Reads go to the API server while the cache is more stale than
+// opts.MaxStaleness. Calling EnableCache again stops the informers of the previous cache first.
+//
+// Cached reads are eventually consistent with the API server: for instance GetPersistentVolumeClaim
+// can return NotFound right after the PVC was created, until the informer sees it.
+func (c *Client) EnableCache(ctx context.Context, opts *CacheOptions) error {
+	if opts == nil {
+		opts = &CacheOptions{}
+	}
+	if err := c.initClient(); err != nil {
+		return err
+	}
+	var podFieldSelector string
+	if opts.PodFieldSelector != "" {
+		selector, err := fields.ParseSelector(opts.PodFieldSelector)
+		if err != nil {
+			return fmt.Errorf("invalid pod field selector %q: %v", opts.PodFieldSelector, err)
+		}
+		podFieldSelector = selector.String()
+	}
+	c.disableCache()
+
+	cc := &clientCache{
+		maxStaleness:     opts.MaxStaleness,
+		namespace:        opts.Namespace,
+		podFieldSelector: podFieldSelector,
+	}
+	if cc.maxStaleness <= 0 {
+		cc.maxStaleness = defaultCacheMaxStaleness
+	}
+	cc.ctx, cc.cancel = context.WithCancel(ctx)
+	if err := cc.start(c, opts.ResyncPeriod); err != nil {
+		cc.cancel()
+		return err
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cache != nil {
+		// EnableCache was called concurrently
+		c.cache.cancel()
+	}
+	c.cache = cc
+	return nil
+}
+
+// start starts the informers of the cache and waits for them to be synced
+func (cc *clientCache) start(c *Client, resyncPeriod time.Duration) error {
+	pods := c.kubernetes.CoreV1().Pods(cc.namespace)
+	pvcs := c.kubernetes.CoreV1().PersistentVolumeClaims(cc.namespace)
+
+	var err error
+	cc.pods, err = newCachedInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = cc.podFieldSelector
+			return pods.List(cc.ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = cc.podFieldSelector
+			return pods.Watch(cc.ctx, options)
+		},
+	}, &corev1.Pod{}, resyncPeriod, cache.Indexers{
+		podsByPVCIndex:  podPVCIndexFunc,
+		podsByNodeIndex: podNodeIndexFunc,
+	})
+	if err != nil {
+		return err
+	}
+	cc.pvcs, err = newCachedInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return pvcs.List(cc.ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return pvcs.Watch(cc.ctx, options)
+		},
+	}, &corev1.PersistentVolumeClaim{}, resyncPeriod, cache.Indexers{
+		pvcsByStorageClassIndex: pvcStorageClassIndexFunc,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ci := range []*cachedInformer{cc.pods, cc.pvcs} {
+		go ci.informer.Run(cc.ctx.Done())
+	}
+	if !cache.WaitForCacheSync(cc.ctx.Done(), cc.pods.informer.HasSynced, cc.pvcs.informer.HasSynced) {
+		return fmt.Errorf("failed to sync the cache: %v", cc.ctx.Err())
+	}
+	return nil
+}
+
+// disableCache stops the informers of the cache, if it is enabled
+func (c *Client) disableCache() {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cache != nil {
+		c.cache.cancel()
+		c.cache = nil
+	}
+}
+
+// newCachedInformer returns an informer of the objects of lw. The informer clears the watch failure
+// once it lists the objects again, as it does after every failure.
+func newCachedInformer(lw *cache.ListWatch, objType runtime.Object, resyncPeriod time.Duration, indexers cache.Indexers) (*cachedInformer, error) {
+	ci := &cachedInformer{}
+	list := lw.ListFunc
+	lw.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		obj, err := list(options)
+		if err == nil {
+			ci.watchRecovered()
+		}
+		return obj, err
+	}
+	ci.informer = cache.NewSharedIndexInformer(lw, objType, resyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	if err := ci.informer.AddIndexers(indexers); err != nil {
+		return nil, err
+	}
+	if err := ci.informer.SetTransform(stripManagedFields); err != nil {
+		return nil, err
+	}
+	if err := ci.informer.SetWatchErrorHandler(ci.watchFailed); err != nil {
+		return nil, err
+	}
+	return ci, nil
+}
+
+func (ci *cachedInformer) watchFailed(r *cache.Reflector, err error) {
+	ci.mu.Lock()
+	if ci.watchFailedAt.IsZero() {
+		ci.watchFailedAt = time.Now()
+	}
+	ci.mu.Unlock()
+	cache.DefaultWatchErrorHandler(r, err)
+}
+
+func (ci *cachedInformer) watchRecovered() {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.watchFailedAt = time.Time{}
+}
+
+// fresh returns true if the informer is synced and its watch did not fail for longer than maxStaleness
+func (ci *cachedInformer) fresh(maxStaleness time.Duration) bool {
+	if !ci.informer.HasSynced() {
+		return false
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	if !ci.watchFailedAt.IsZero() && time.Since(ci.watchFailedAt) > maxStaleness {
+		logrus.Debugf("cache is stale since %v, reading from the API server", ci.watchFailedAt)
+		return false
+	}
+	return true
+}
+
+// pvcIndexer returns the PVC indexer if the cache is enabled, fresh and has the PVCs of the namespace,
+// or of all the namespaces if it is empty. It returns nil otherwise.
+func (c *Client) pvcIndexer(namespace string) cache.Indexer {
+	cc := c.getCache()
+	if cc == nil || !cc.hasNamespace(namespace) || !cc.pvcs.fresh(cc.maxStaleness) {
+		return nil
+	}
+	return cc.pvcs.informer.GetIndexer()
+}
+
+// hasNamespace returns true if the cache has the objects of the namespace, or of all the namespaces
+// if it is empty
+func (cc *clientCache) hasNamespace(namespace string) bool {
+	return cc.namespace == "" || cc.namespace == namespace
+}
+
+func (c *Client) getCache() *clientCache {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cache != nil && c.cache.ctx.Err() != nil {
+		// the informers are stopped
+		c.cache = nil
+	}
+	return c.cache
+}
+
+// listCachedPods returns the pods in the namespace matching the list options from the cache, using
+// the PVC index if a PVC name is given. It returns false if the cache is disabled, stale or cannot
+// serve the options; only the label selector and a spec.nodeName field selector are supported, or
+// the field selector of the cache if it has one.
+func (c *Client) listCachedPods(namespace string, opts metav1.ListOptions, pvcName string) ([]corev1.Pod, bool) {
+	cc := c.getCache()
+	if cc == nil || !cc.hasNamespace(namespace) || !cc.pods.fresh(cc.maxStaleness) {
+		return nil, false
+	}
+	indexer := cc.pods.informer.GetIndexer()
+
+	labelSelector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, false
+	}
+	var nodeName string
+	if opts.FieldSelector != "" || cc.podFieldSelector != "" {
+		fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, false
+		}
+		if cc.podFieldSelector != "" {
+			// the cache only has the pods matching its field selector
+			if fieldSelector.String() != cc.podFieldSelector {
+				return nil, false
+			}
+			nodeName, _ = fieldSelector.RequiresExactMatch("spec.nodeName")
+		} else {
+			var found bool
+			if len(fieldSelector.Requirements()) != 1 {
+				return nil, false
+			}
+			if nodeName, found = fieldSelector.RequiresExactMatch("spec.nodeName"); !found {
+				return nil, false
+			}
+		}
+	}
+
+	var pods []corev1.Pod
+	switch {
+	case pvcName != "":
+		pods, err = cachedPods(indexer, podsByPVCIndex, namespace+"/"+pvcName)
+	case nodeName != "":
+		pods, err = cachedPods(indexer, podsByNodeIndex, nodeName)
+	case namespace != "":
+		pods, err = cachedPods(indexer, cache.NamespaceIndex, namespace)
+	default:
+		pods, err = cachedPods(indexer, "", "")
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	matching := pods[:0]
+	for _, pod := range pods {
+		if namespace != "" && pod.Namespace != namespace {
+			continue
+		}
+		if nodeName != "" && pod.Spec.NodeName != nodeName {
+			continue
+		}
+		if !labelSelector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		matching = append(matching, pod)
+	}
+	return matching, true
+}
+
+// cachedPVCsUsingStorageClass returns the PVCs of the storage class from the cache. Like
+// GetPVCsUsingStorageClass, no PVC is returned if the storage class cannot be found.
+func (c *Client) cachedPVCsUsingStorageClass(indexer cache.Indexer, scName string) ([]corev1.PersistentVolumeClaim, error) {
+	if _, err := c.kubernetes.StorageV1().StorageClasses().Get(context.TODO(), scName, metav1.GetOptions{}); err != nil {
+		return nil, nil
+	}
+
+	objs, err := indexer.ByIndex(pvcsByStorageClassIndex, scName)
+	if err != nil {
+		return nil, err
+	}
+	var retList []corev1.PersistentVolumeClaim
+	for _, obj := range objs {
+		if pvc, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+			retList = append(retList, *pvc.DeepCopy())
+		}
+	}
+	return retList, nil
+}
+
+// cachedPods returns deep copies of the pods of the indexer with the given index value, or all the
+// pods if the index name is empty
+func cachedPods(indexer cache.Indexer, indexName, indexValue string) ([]corev1.Pod, error) {
+	var objs []interface{}
+	if indexName == "" {
+		objs = indexer.List()
+	} else {
+		var err error
+		if objs, err = indexer.ByIndex(indexName, indexValue); err != nil {
+			return nil, err
+		}
+	}
+
+	pods := make([]corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pods = append(pods, *pod.DeepCopy())
+		}
+	}
+	return pods, nil
+}
+
+// cachedPVC returns a deep copy of the PVC from the indexer, or a NotFound error
+func cachedPVC(indexer cache.Indexer, pvcName, namespace string) (*corev1.PersistentVolumeClaim, error) {
+	obj, exists, err := indexer.GetByKey(namespace + "/" + pvcName)
+	if err != nil {
+		return nil, err
+	}
+	pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+	if !exists || !ok {
+		return nil, apierrors.NewNotFound(corev1.Resource("persistentvolumeclaims"), pvcName)
+	}
+	return pvc.DeepCopy(), nil
+}
+
+func podPVCIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+	var keys []string
+	for _, v := range pod.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil {
+			keys = append(keys, pod.Namespace+"/"+v.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return keys, nil
+}
+
+func podNodeIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// pvcStorageClassIndexFunc indexes the PVCs by storage class name in the same way as
+// GetStorageClassForPVC resolves it
+func pvcStorageClassIndexFunc(obj interface{}) ([]string, error) {
+	pvc, ok := obj.(*corev1.PersistentVolumeClaim)
+	if !ok {
+		return nil, nil
+	}
+	if pvc.Spec.StorageClassName != nil && len(*pvc.Spec.StorageClassName) > 0 {
+		return []string{*pvc.Spec.StorageClassName}, nil
+	}
+	if scName := pvc.Annotations[corev1.BetaStorageClassAnnotation]; scName != "" {
+		return []string{scName}, nil
+	}
+	return nil, nil
+}
+
+// stripManagedFields drops the managed fields of the cached objects, which are not used by the
+// client and account for a large part of their size
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, ok := obj.(metav1.ObjectMetaAccessor); ok {
+		accessor.GetObjectMeta().SetManagedFields(nil)
+	}
+	return obj, nil
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newPVCPod(name, node, pvcName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1", Labels: map[string]string{"app": name}},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+				},
+			}},
+			Containers: []corev1.Container{{Name: "c1", VolumeMounts: []corev1.VolumeMount{{Name: "data"}}}},
+		},
+	}
+}
+
+func newCachedClient(t *testing.T, ctx context.Context, opts *CacheOptions) (*Client, *fake.Clientset, *int32) {
+	scName := "sc1"
+	clientset := fake.NewSimpleClientset(
+		newPVCPod("pod1", "node1", "pvc1"),
+		newPVCPod("pod2", "node2", "pvc1"),
+		newPVCPod("pod3", "node1", "pvc2"),
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc1", Namespace: "ns1"},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &scName},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pvc2",
+				Namespace:   "ns1",
+				Annotations: map[string]string{corev1.BetaStorageClassAnnotation: "sc2"},
+			},
+		},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "sc1"}},
+	)
+	var apiReads int32
+	countReads := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		atomic.AddInt32(&apiReads, 1)
+		return false, nil, nil
+	}
+	clientset.PrependReactor("list", "pods", countReads)
+	clientset.PrependReactor("get", "persistentvolumeclaims", countReads)
+
+	client := New(clientset)
+	require.NoError(t, client.EnableCache(ctx, opts))
+	atomic.StoreInt32(&apiReads, 0)
+	return client, clientset, &apiReads
+}
+
+func TestCachedReads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, clientset, apiReads := newCachedClient(t, ctx, nil)
+
+	pods, err := client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pod1", "pod2"}, podNames(pods))
+	pods, err = client.GetPodsUsingPVCByNodeName("pvc1", "ns1", "node1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"pod1"}, podNames(pods))
+
+	podList, err := client.GetPodsByNode("node1", "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pod1", "pod3"}, podNames(podList.Items))
+	podList, err = client.GetPods("ns1", map[string]string{"app": "pod2"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"pod2"}, podNames(podList.Items))
+
+	pvc, err := client.GetPersistentVolumeClaim("pvc2", "ns1")
+	require.NoError(t, err)
+	require.Equal(t, "pvc2", pvc.Name)
+	_, err = client.GetPersistentVolumeClaim("missing", "ns1")
+	require.Error(t, err)
+
+	pvcs, err := client.GetPVCsUsingStorageClass("sc1")
+	require.NoError(t, err)
+	require.Len(t, pvcs, 1)
+	require.Equal(t, "pvc1", pvcs[0].Name)
+	require.Zero(t, atomic.LoadInt32(apiReads), "Expected the reads to be served from the cache")
+
+	// the cache follows the changes
+	_, err = clientset.CoreV1().Pods("ns1").Create(context.TODO(), newPVCPod("pod4", "node3", "pvc1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		pods, err := client.GetPodsUsingPVC("pvc1", "ns1")
+		return err == nil && len(pods) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the returned pods are copies
+	pods[0].Labels["app"] = "changed"
+	pods, err = client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	for _, pod := range pods {
+		require.Equal(t, pod.Name, pod.Labels["app"])
+	}
+	require.Zero(t, atomic.LoadInt32(apiReads))
+
+	// unsupported field selectors go to the API server
+	_, err = client.getPodsWithListOptions("", metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(apiReads))
+
+	cancel()
+	_, err = client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(apiReads), "Expected the cache to be disabled once ctx is done")
+}
+
+func TestCacheStaleness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, _, apiReads := newCachedClient(t, ctx, &CacheOptions{MaxStaleness: time.Minute})
+	cc := client.getCache()
+
+	// a watch failure within the staleness bound still serves cached reads
+	cc.pods.mu.Lock()
+	cc.pods.watchFailedAt = time.Now()
+	cc.pods.mu.Unlock()
+	_, err := client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.Zero(t, atomic.LoadInt32(apiReads))
+
+	cc.pods.mu.Lock()
+	cc.pods.watchFailedAt = time.Now().Add(-2 * time.Minute)
+	cc.pods.mu.Unlock()
+	pods, err := client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.Len(t, pods, 2)
+	require.Equal(t, int32(1), atomic.LoadInt32(apiReads), "Expected a stale cache to be bypassed")
+
+	cc.pods.watchRecovered()
+	_, err = client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(apiReads))
+}
+
+func TestCacheRecoversOnRelist(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientset := fake.NewSimpleClientset()
+	// the first watch of the pods is closed by the test, the second fails so that the informer
+	// lists the pods again
+	firstWatch := watch.NewFake()
+	var watches int32
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		switch atomic.AddInt32(&watches, 1) {
+		case 1:
+			return true, firstWatch, nil
+		case 2:
+			return true, nil, fmt.Errorf("watch failed")
+		}
+		return false, nil, nil
+	})
+	client := New(clientset)
+	require.NoError(t, client.EnableCache(ctx, &CacheOptions{MaxStaleness: time.Minute}))
+	cc := client.getCache()
+
+	// the informer has no pods, so no event clears the failure
+	cc.pods.mu.Lock()
+	cc.pods.watchFailedAt = time.Now().Add(-2 * time.Minute)
+	cc.pods.mu.Unlock()
+	require.False(t, cc.pods.fresh(time.Minute))
+
+	firstWatch.Stop()
+	require.Eventually(t, func() bool {
+		return cc.pods.fresh(time.Minute)
+	}, 10*time.Second, 10*time.Millisecond, "Expected the relist to clear the watch failure")
+}
+
+func TestCacheScope(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, clientset, apiReads := newCachedClient(t, ctx, &CacheOptions{
+		Namespace:        "ns1",
+		PodFieldSelector: "spec.nodeName=node1",
+	})
+	other := newPVCPod("other", "node1", "pvc1")
+	other.Namespace = "ns2"
+	_, err := clientset.CoreV1().Pods("ns2").Create(context.TODO(), other, metav1.CreateOptions{})
+	require.NoError(t, err)
+	cc := client.getCache()
+	for _, action := range clientset.Actions() {
+		if list, ok := action.(k8stesting.ListActionImpl); ok && list.GetResource().Resource == "pods" {
+			require.Equal(t, "ns1", list.GetNamespace())
+			require.Equal(t, "spec.nodeName=node1", list.GetListRestrictions().Fields.String(),
+				"Expected only the pods of the node to be cached")
+		}
+	}
+
+	podList, err := client.GetPodsByNode("node1", "ns1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pod1", "pod3"}, podNames(podList.Items))
+	pods, err := client.GetPodsUsingPVCByNodeName("pvc1", "ns1", "node1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"pod1"}, podNames(pods))
+	_, err = client.GetPersistentVolumeClaim("pvc1", "ns1")
+	require.NoError(t, err)
+	require.Zero(t, atomic.LoadInt32(apiReads), "Expected the reads in scope to be served from the cache")
+
+	// reads out of the scope of the cache go to the API server
+	pods, err = client.GetPodsUsingPVC("pvc1", "ns1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pod1", "pod2"}, podNames(pods))
+	_, err = client.GetPodsByNode("node1", "")
+	require.NoError(t, err)
+	_, err = client.GetPersistentVolumeClaim("pvc1", "ns2")
+	require.Error(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(apiReads))
+
+	// enabling the cache again stops the previous informers
+	require.NoError(t, client.EnableCache(ctx, nil))
+	require.Error(t, cc.ctx.Err(), "Expected the previous cache to be stopped")
+	cc = client.getCache()
+	client.SetConfig(nil)
+	require.Error(t, cc.ctx.Err(), "Expected SetConfig to stop the cache")
+	require.Nil(t, client.getCache())
+
+	require.Error(t, client.EnableCache(ctx, &CacheOptions{PodFieldSelector: "spec.nodeName"}))
+}
+
+func podNames(pods []corev1.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	return names
+}
@@ -16,6 +16,8 @@ import (
 
 // New returns the ConfigMap interface. It also creates a new
 // configmap in k8s for the given name if not present and puts the data in it.
+// The v2 locks are stored in the configmap unless another backend is selected
+// with WithLockBackend.
 func New(
 	name string,
 	data map[string]string,
@@ -23,53 +25,100 @@ func New(
 	lockAttempts uint,
 	v2LockRefreshDuration time.Duration,
 	v2LockK8sLockTTL time.Duration,
+	opts ...Option,
 ) (ConfigMap, error) {
-	if data == nil {
-		data = make(map[string]string)
+	return NewWithOptions(name, data, append([]Option{
+		WithLockHoldTimeout(lockTimeout),
+		WithLockAttempts(lockAttempts),
+		WithLockRefreshDuration(v2LockRefreshDuration),
+		WithLockTTL(v2LockK8sLockTTL),
+	}, opts...)...)
+}
+
+// NewWithOptions is similar to New but takes all the settings as options. Without options the
+// configmap is created in the kube-system namespace using core.Instance(), with the default
+// lock hold timeout, lock attempts, refresh duration and TTL.
+func NewWithOptions(name string, data map[string]string, opts ...Option) (ConfigMap, error) {
+	c := &configMap{
+		name:                   name,
+		namespace:              k8sSystemNamespace,
+		defaultLockHoldTimeout: DefaultK8sLockTimeout,
+		kLocksV2:               map[string]*k8sLock{},
+		kRLocks:                map[readerLockID]*k8sLock{},
+		lockAttempts:           DefaultK8sLockAttempts,
+		lockBackend:            LockBackendConfigMap,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.lockK8sLockTTL == 0 {
+		c.lockK8sLockTTL = v2DefaultK8sLockTTL
+	}
+	if c.lockRefreshDuration == 0 {
+		c.lockRefreshDuration = v2DefaultK8sLockRefreshDuration
+	}
+	if c.lockRefreshDuration >= c.renewDeadline() {
+		// the leases would end before their first refresh
+		return nil, fmt.Errorf("lock refresh duration %v must be less than two thirds of the lock TTL %v",
+			c.lockRefreshDuration, c.lockK8sLockTTL)
 	}
 
-	labels := map[string]string{
-		configMapUserLabelKey: TruncateLabel(name),
+	if data == nil {
+		data = make(map[string]string)
 	}
 	data[pxOwnerKey] = ""
 
 	cm := &corev1.ConfigMap{
-		ObjectMeta: meta_v1.ObjectMeta{
-			Name:      name,
-			Namespace: k8sSystemNamespace,
-			Labels:    labels,
-		},
-		Data: data,
+		ObjectMeta: c.objectMeta(name),
+		Data:       data,
 	}
 
-	if _, err := core.Instance().CreateConfigMap(cm); err != nil &&
+	if _, err := c.ops().CreateConfigMap(cm); err != nil &&
 		!k8s_errors.IsAlreadyExists(err) {
 		return nil, fmt.Errorf("failed to create configmap %v: %v",
 			name, err)
 	}
+	return c, nil
+}
 
-	if v2LockK8sLockTTL == 0 {
-		v2LockK8sLockTTL = v2DefaultK8sLockTTL
+// ops returns the client used to access k8s.
+func (c *configMap) ops() core.Ops {
+	if c.coreOps != nil {
+		return c.coreOps
 	}
+	return core.Instance()
+}
 
-	if v2LockRefreshDuration == 0 {
-		v2LockRefreshDuration = v2DefaultK8sLockRefreshDuration
+// objectMeta returns the metadata for a new k8s object, the ConfigMap or one of its Leases, with the
+// given name. The user label always refers to the configMap name.
+func (c *configMap) objectMeta(name string) meta_v1.ObjectMeta {
+	labels := map[string]string{}
+	for k, v := range c.labels {
+		labels[k] = v
 	}
+	labels[configMapUserLabelKey] = TruncateLabel(c.name)
 
-	return &configMap{
-		name:                   name,
-		defaultLockHoldTimeout: lockTimeout,
-		kLocksV2:               map[string]*k8sLock{},
-		lockAttempts:           lockAttempts,
-		lockRefreshDuration:    v2LockRefreshDuration,
-		lockK8sLockTTL:         v2LockK8sLockTTL,
-	}, nil
+	var annotations map[string]string
+	if len(c.annotations) > 0 {
+		annotations = map[string]string{}
+		for k, v := range c.annotations {
+			annotations[k] = v
+		}
+	}
+
+	return meta_v1.ObjectMeta{
+		Name:            name,
+		Namespace:       c.namespace,
+		Labels:          labels,
+		Annotations:     annotations,
+		OwnerReferences: c.ownerReferences,
+	}
 }
 
 func (c *configMap) Get() (map[string]string, error) {
-	cm, err := core.Instance().GetConfigMap(
+	cm, err := c.ops().GetConfigMap(
 		c.name,
-		k8sSystemNamespace,
+		c.namespace,
 	)
 	if err != nil {
 		return nil, err
@@ -79,9 +128,14 @@ func (c *configMap) Get() (map[string]string, error) {
 }
 
 func (c *configMap) Delete() error {
-	return core.Instance().DeleteConfigMap(
+	if c.lockBackend != LockBackendConfigMap {
+		if err := c.deleteLeases(); err != nil {
+			return err
+		}
+	}
+	return c.ops().DeleteConfigMap(
 		c.name,
-		k8sSystemNamespace,
+		c.namespace,
 	)
 }
 
@@ -91,9 +145,9 @@ func (c *configMap) PatchKeyLocked(isV1Lock bool, lockOwner, key, val string) er
 		cm  *corev1.ConfigMap
 	)
 	for retries := 0; retries < maxConflictRetries; retries++ {
-		cm, err = core.Instance().GetConfigMap(
+		cm, err = c.ops().GetConfigMap(
 			c.name,
-			k8sSystemNamespace,
+			c.namespace,
 		)
 		if err != nil {
 			return err
@@ -107,14 +161,14 @@ func (c *configMap) PatchKeyLocked(isV1Lock bool, lockOwner, key, val string) er
 
 		cm.Data[key] = val
 		newGen := c.incrementGeneration(cm)
-		_, err = core.Instance().UpdateConfigMap(cm)
+		_, err = c.ops().UpdateConfigMap(cm)
 		if k8s_errors.IsConflict(err) {
 			// try again
 			continue
 		}
 		if err == nil {
 			logrus.Infof("Updated key %s in configmap %s/%s with generation %d and lockOwner %s",
-				key, k8sSystemNamespace, c.name, newGen, lockOwner)
+				key, c.namespace, c.name, newGen, lockOwner)
 		}
 		return err
 	}
@@ -154,11 +208,26 @@ func (c *configMap) checkLockOwner(isV1Lock bool, v2Key string, expectedLockOwne
 	if v2Key == "" {
 		return errors.New("v2 key cannot be empty when checking v2 lock owner")
 	}
+	if c.lockBackend == LockBackendLease {
+		currentOwner, _, err := c.getLeaseLockHolder(v2Key)
+		if err != nil {
+			return fmt.Errorf("failed to get v2 lease: %w", err)
+		}
+		if currentOwner != expectedLockOwner {
+			return fmt.Errorf("v2 lock owner is %q instead of expected %q", currentOwner, expectedLockOwner)
+		}
+		return nil
+	}
 	currentOwner, err := c.getV2LockOwnerIncludeExpired(cm, v2Key)
 	if err != nil {
 		return fmt.Errorf("failed to parse v2 locks: %w", err)
 	}
 	if currentOwner != expectedLockOwner {
+		if readers, err := c.parseReaderLocks(cm); err == nil {
+			if _, ok := readers[v2Key][expectedLockOwner]; ok {
+				return fmt.Errorf("%q holds a shared v2 lock but an exclusive lock is required", expectedLockOwner)
+			}
+		}
 		return fmt.Errorf("v2 lock owner is %q instead of expected %q", currentOwner, expectedLockOwner)
 	}
 	return nil
@@ -170,9 +239,9 @@ func (c *configMap) DeleteKeyLocked(isV1Lock bool, lockOwner string, key string)
 		cm  *corev1.ConfigMap
 	)
 	for retries := 0; retries < maxConflictRetries; retries++ {
-		cm, err = core.Instance().GetConfigMap(
+		cm, err = c.ops().GetConfigMap(
 			c.name,
-			k8sSystemNamespace,
+			c.namespace,
 		)
 		if err != nil {
 			return err
@@ -183,14 +252,14 @@ func (c *configMap) DeleteKeyLocked(isV1Lock bool, lockOwner string, key string)
 		delete(cm.Data, key)
 
 		newGen := c.incrementGeneration(cm)
-		_, err = core.Instance().UpdateConfigMap(cm)
+		_, err = c.ops().UpdateConfigMap(cm)
 		if k8s_errors.IsConflict(err) {
 			// try again
 			continue
 		}
 		if err == nil {
 			logrus.Infof("Deleted key %s in configmap %s/%s with generation %d and lockOwner %s",
-				key, k8sSystemNamespace, c.name, newGen, lockOwner)
+				key, c.namespace, c.name, newGen, lockOwner)
 		}
 		return err
 	}
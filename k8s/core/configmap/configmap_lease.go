@@ -0,0 +1,54 @@
+package configmap
+
+import (
+	"context"
+)
+
+// lease implements Lease on top of a v2 lock.
+type lease struct {
+	cm    *configMap
+	owner string
+	key   string
+	lock  *k8sLock
+	ctx   context.Context
+}
+
+func (l *lease) Key() string {
+	return l.key
+}
+
+func (l *lease) Owner() string {
+	return l.owner
+}
+
+func (l *lease) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+func (l *lease) Context() context.Context {
+	return l.ctx
+}
+
+func (l *lease) Err() error {
+	if l.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(l.ctx)
+}
+
+func (l *lease) Unlock() error {
+	return l.release(ErrConfigMapLockReleased)
+}
+
+// release releases the lock, ending the lease with the given cause.
+func (l *lease) release(cause error) error {
+	// The key may have been locked again by this process after the lease was lost. Only release
+	// the lock if it is still the one backing this lease.
+	l.cm.kLocksV2Mutex.Lock()
+	current := l.cm.kLocksV2[l.key]
+	l.cm.kLocksV2Mutex.Unlock()
+	if current != l.lock {
+		return nil
+	}
+	return l.cm.unlockWithKey(l.key, cause)
+}
@@ -0,0 +1,134 @@
+package configmap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coreops "github.com/portworx/sched-ops/k8s/core"
+	"github.com/stretchr/testify/require"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func setUpFakeConfigMapClient() {
+	coreops.SetInstance(coreops.New(fake.NewSimpleClientset()))
+}
+
+func TestLeaseLost(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-lease-lost-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	id1 := "lease-id1"
+	key1 := "lease-key1"
+
+	lease, err := cm.LockWithKeyCtx(context.Background(), id1, key1)
+	require.NoError(t, err, "Unexpected error in LockWithKeyCtx(id1,key1)")
+	require.Equal(t, id1, lease.Owner())
+	require.Equal(t, key1, lease.Key())
+	require.NoError(t, lease.Err())
+
+	// steal the lock from under the lease
+	rawCM, err := coreops.Instance().GetConfigMap(cm.(*configMap).name, k8sSystemNamespace)
+	require.NoError(t, err)
+	err = cm.(*configMap).generateConfigMapData(rawCM,
+		map[string]string{key1: "lease-id2"}, map[string]time.Time{key1: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	_, err = coreops.Instance().UpdateConfigMap(rawCM)
+	require.NoError(t, err)
+
+	select {
+	case <-lease.Done():
+	case <-time.After(5 * testLockRefreshDuration):
+		t.Fatal("lease was not cancelled after the lock was lost")
+	}
+	require.ErrorIs(t, lease.Err(), ErrConfigMapLockLost)
+	require.ErrorIs(t, context.Cause(lease.Context()), ErrConfigMapLockLost)
+	require.NoError(t, lease.Unlock())
+}
+
+func TestLeaseUnlock(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-lease-unlock-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	id1 := "lease-id1"
+	id2 := "lease-id2"
+	key1 := "lease-key1"
+
+	lease, err := cm.LockWithKeyCtx(context.Background(), id1, key1)
+	require.NoError(t, err, "Unexpected error in LockWithKeyCtx(id1,key1)")
+
+	// waiting for the lock stops when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = cm.LockWithKeyCtx(ctx, id2, key1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), testLockAttempts*lockSleepDuration)
+
+	require.NoError(t, lease.Unlock())
+	<-lease.Done()
+	require.ErrorIs(t, lease.Err(), ErrConfigMapLockReleased)
+
+	locked, _, err := cm.IsKeyLocked(key1, id2)
+	require.NoError(t, err)
+	require.False(t, locked)
+}
+
+func TestLeaseContextDone(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-lease-ctx-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lease, err := cm.LockWithKeyCtx(ctx, "lease-ctx-id1", "lease-ctx-key1")
+	require.NoError(t, err, "Unexpected error in LockWithKeyCtx(id1,key1)")
+	time.Sleep(2 * testLockRefreshDuration)
+	require.NoError(t, lease.Err(), "Expected the lease to be held while its context is not done")
+
+	// the lock is released, not only the lease ended, once the context is done
+	cancel()
+	<-lease.Done()
+	require.ErrorIs(t, lease.Err(), context.Canceled)
+	require.Eventually(t, func() bool {
+		locked, _, err := cm.IsKeyLocked("lease-ctx-key1", "lease-ctx-id2")
+		return err == nil && !locked
+	}, 5*time.Second, 10*time.Millisecond, "Expected the lock to be released")
+}
+
+func TestLeaseRefreshDeadline(t *testing.T) {
+	var unreachable atomic.Bool
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if unreachable.Load() {
+			return true, nil, k8s_errors.NewServiceUnavailable("apiserver unreachable")
+		}
+		return false, nil, nil
+	})
+	coreops.SetInstance(coreops.New(clientset))
+	// the lock hold timeout is disabled so that only the refresh failures end the lease
+	cm, err := NewWithOptions("px-configmaps-lease-deadline-test", nil, WithLockHoldTimeout(0),
+		WithLockRefreshDuration(testLockRefreshDuration), WithLockTTL(testLockTTL))
+	require.NoError(t, err, "Unexpected error on NewWithOptions")
+
+	lease, err := cm.LockWithKeyCtx(context.Background(), "lease-id1", "lease-key1")
+	require.NoError(t, err, "Unexpected error in LockWithKeyCtx(id1,key1)")
+
+	// the lock keeps being refreshed while the apiserver is reachable
+	time.Sleep(testLockTTL)
+	require.NoError(t, lease.Err())
+
+	// the lease ends before the lock expires when it cannot be refreshed anymore
+	unreachable.Store(true)
+	select {
+	case <-lease.Done():
+	case <-time.After(testLockTTL):
+		t.Fatal("lease was not cancelled before the lock TTL")
+	}
+	require.ErrorIs(t, lease.Err(), ErrConfigMapLockLost)
+}
@@ -0,0 +1,126 @@
+package configmap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func (c *configMap) ListLocks() ([]LockInfo, error) {
+	cm, err := c.ops().GetConfigMap(
+		c.name,
+		c.namespace,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []lockData
+	if c.lockBackend != LockBackendLease {
+		if locks, err = readLockData(cm); err != nil {
+			return nil, fmt.Errorf("failed to get locks from configmap: %v", err)
+		}
+	}
+	if c.lockBackend != LockBackendConfigMap {
+		leaseLocks, err := c.listLeaseLocks()
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, leaseLocks...)
+	}
+
+	now := time.Now()
+	generation := getGeneration(cm)
+	seen := map[lockData]bool{}
+	infos := []LockInfo{}
+	for _, lock := range locks {
+		// with LockBackendMigrating the same lock is usually found in both the ConfigMap and the Lease
+		id := lockData{Owner: lock.Owner, Key: lock.Key, Shared: lock.Shared}
+		if !now.Before(lock.Expiration) || seen[id] {
+			continue
+		}
+		seen[id] = true
+		infos = append(infos, LockInfo{
+			Key:        lock.Key,
+			Owner:      lock.Owner,
+			Shared:     lock.Shared,
+			Expiration: lock.Expiration,
+			Remaining:  lock.Expiration.Sub(now),
+			Generation: generation,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Key != infos[j].Key {
+			return infos[i].Key < infos[j].Key
+		}
+		return infos[i].Owner < infos[j].Owner
+	})
+	return infos, nil
+}
+
+func (c *configMap) ForceUnlock(key, reason string) error {
+	if key == "" {
+		return errors.New("key cannot be empty")
+	}
+	if reason == "" {
+		return errors.New("reason cannot be empty")
+	}
+
+	fn := "ForceUnlock"
+	owner, _, err := c.getLockHolder(key)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		configMapLog(fn, c.name, "", key, nil).Infof("Key is not locked")
+		return nil
+	}
+
+	configMapLog(fn, c.name, owner, key, nil).Warnf("Force unlocking: %s", reason)
+	if err := c.releaseLock(owner, key); err != nil {
+		return fmt.Errorf("failed to force unlock key %s held by %s: %w", key, owner, err)
+	}
+
+	cm, err := c.ops().GetConfigMap(
+		c.name,
+		c.namespace,
+	)
+	if err != nil {
+		configMapLog(fn, c.name, owner, key, err).Errorf("Failed to get configmap to record the force unlock event")
+		return nil
+	}
+	c.ops().RecordEventf(lockEventReportingController, cm, nil, v1.EventTypeWarning,
+		lockForceUnlockReason, "ForceUnlock", "Lock on key %s held by %s was force unlocked: %s", key, owner, reason)
+	return nil
+}
+
+// listLeaseLocks returns the v2 locks held in the Lease objects of the configMap.
+func (c *configMap) listLeaseLocks() ([]lockData, error) {
+	leases, err := c.listLeases()
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []lockData
+	for _, lease := range leases {
+		owner, expiration := leaseHolder(lease)
+		if owner == "" {
+			continue
+		}
+		locks = append(locks, lockData{Owner: owner, Key: lease.Annotations[leaseLockKeyAnnotation], Expiration: expiration})
+	}
+	return locks, nil
+}
+
+// getGeneration returns the generation of the given ConfigMap data, or 0 if it has none.
+func getGeneration(cm *v1.ConfigMap) uint64 {
+	gen, err := strconv.ParseUint(cm.Data[pxGenerationKey], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return gen
+}
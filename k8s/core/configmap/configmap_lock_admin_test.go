@@ -0,0 +1,109 @@
+package configmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreops "github.com/portworx/sched-ops/k8s/core"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestListLocks(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-list-locks-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	locks, err := cm.ListLocks()
+	require.NoError(t, err)
+	require.Empty(t, locks)
+
+	require.NoError(t, cm.LockWithKey("list-id2", "list-key2"))
+	require.NoError(t, cm.RLockWithKey("list-id1", "list-key1"))
+	require.NoError(t, cm.RLockWithKey("list-id3", "list-key1"))
+	require.NoError(t, cm.PatchKeyLocked(false, "list-id2", "list-key2", "val"))
+
+	locks, err = cm.ListLocks()
+	require.NoError(t, err)
+	require.Len(t, locks, 3)
+	require.Equal(t, "list-key1", locks[0].Key)
+	require.Equal(t, "list-id1", locks[0].Owner)
+	require.True(t, locks[0].Shared)
+	require.Equal(t, "list-key1", locks[1].Key)
+	require.Equal(t, "list-id3", locks[1].Owner)
+	require.Equal(t, "list-key2", locks[2].Key)
+	require.Equal(t, "list-id2", locks[2].Owner)
+	require.False(t, locks[2].Shared)
+	for _, lock := range locks {
+		require.Equal(t, uint64(1), lock.Generation)
+		require.True(t, lock.Remaining > 0 && lock.Remaining <= testLockTTL)
+	}
+
+	require.NoError(t, cm.UnlockWithKey("list-key2"))
+	require.NoError(t, cm.RUnlockWithKey("list-id1", "list-key1"))
+	require.NoError(t, cm.RUnlockWithKey("list-id3", "list-key1"))
+	locks, err = cm.ListLocks()
+	require.NoError(t, err)
+	require.Empty(t, locks)
+}
+
+func TestListLocksLeaseBackend(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-list-locks-lease-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL,
+		WithLockBackend(LockBackendMigrating))
+	require.NoError(t, err, "Unexpected error on New")
+
+	require.NoError(t, cm.LockWithKey("list-id1", "list-key1"))
+	locks, err := cm.ListLocks()
+	require.NoError(t, err)
+	require.Len(t, locks, 1, "Lock held in both the ConfigMap and the Lease must be listed once")
+	require.Equal(t, "list-id1", locks[0].Owner)
+	require.NoError(t, cm.UnlockWithKey("list-key1"))
+
+	locks, err = cm.ListLocks()
+	require.NoError(t, err)
+	require.Empty(t, locks)
+}
+
+func TestForceUnlock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	coreops.SetInstance(coreops.New(client))
+	name := "px-configmaps-force-unlock-test"
+	cm, err := New(name, nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	require.Error(t, cm.ForceUnlock("force-key1", ""), "Expected an error without a reason")
+	require.NoError(t, cm.ForceUnlock("force-key1", "not locked"))
+
+	lease, err := cm.LockWithKeyCtx(context.Background(), "force-id1", "force-key1")
+	require.NoError(t, err)
+	require.NoError(t, cm.ForceUnlock("force-key1", "node is stuck"))
+
+	locked, _, err := cm.IsKeyLocked("force-key1", "force-id2")
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	select {
+	case <-lease.Done():
+	case <-time.After(5 * testLockRefreshDuration):
+		t.Fatal("owner of the lock did not notice that it was force unlocked")
+	}
+	require.ErrorIs(t, lease.Err(), ErrConfigMapLockLost)
+
+	require.Eventually(t, func() bool {
+		events, err := client.EventsV1().Events(k8sSystemNamespace).List(context.TODO(), meta_v1.ListOptions{})
+		require.NoError(t, err)
+		for _, event := range events.Items {
+			if event.Regarding.Name == name && event.Reason == lockForceUnlockReason {
+				require.Equal(t, v1.EventTypeWarning, event.Type)
+				require.Contains(t, event.Note, "node is stuck")
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 100*time.Millisecond, "force unlock event was not recorded")
+	require.NoError(t, lease.Unlock())
+}
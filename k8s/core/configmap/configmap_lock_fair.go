@@ -0,0 +1,232 @@
+package configmap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// lockWaiter structs are serialized into JSON and stored as a list inside the ConfigMap
+// when fair locking is enabled. The list is ordered by arrival time. Each waiter extends
+// its expiration while it waits for the lock once less than half of its TTL is left; waiters
+// that stop doing so are dropped from the queue once their expiration has passed.
+type lockWaiter struct {
+	Owner      string    `json:"owner"`
+	Key        string    `json:"key"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func (c *configMap) SetFairLocking(enabled bool) {
+	c.fairLocking.Store(enabled)
+}
+
+// tryAcquire makes a single attempt to take the v2 lock for the given key from the configured lock
+// backend. It uses the waiter queue if fair locking is enabled for the ConfigMap backend.
+func (c *configMap) tryAcquire(owner, key string) (string, error) {
+	switch c.lockBackend {
+	case LockBackendLease:
+		return c.tryLockLease(owner, key, false)
+	case LockBackendMigrating:
+		return c.tryLockMigrating(owner, key)
+	}
+	if c.fairLocking.Load() {
+		return c.tryLockFair(owner, key)
+	}
+	return c.tryLock(owner, key, false)
+}
+
+// giveUpAcquire is called when LockWithKey stops waiting for the lock without getting it.
+func (c *configMap) giveUpAcquire(owner, key string) {
+	if !c.fairLocking.Load() || c.lockBackend == LockBackendLease {
+		return
+	}
+	if err := c.leaveLockQueue(owner, key); err != nil {
+		configMapLog("giveUpAcquire", c.name, owner, key, err).Warnf(
+			"Failed to remove waiter from the lock queue; it will be removed once it expires")
+	}
+}
+
+// tryLockFair takes the lock only if it is available and the owner is the first waiter for the key.
+// Otherwise the owner is added to the end of the waiter queue, or its expiration in the queue is
+// extended if it is already waiting and less than half of its TTL is left.
+func (c *configMap) tryLockFair(owner, key string) (string, error) {
+	cm, err := c.ops().GetConfigMap(
+		c.name,
+		c.namespace,
+	)
+	if err != nil {
+		// A ConfigMap should always be created.
+		return "", err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+
+	lockIDs, lockExpirations, err := c.parseLocks(cm)
+	if err != nil {
+		return "", fmt.Errorf("failed to get locks from configmap: %v", err)
+	}
+	queue, err := c.parseLockQueue(cm)
+	if err != nil {
+		return "", fmt.Errorf("failed to get lock queue from configmap: %v", err)
+	}
+
+	now := time.Now()
+	queue = removeExpiredWaiters(queue, now)
+	head, pos := -1, -1
+	for i, w := range queue {
+		if w.Key != key {
+			continue
+		}
+		if head < 0 {
+			head = i
+		}
+		if w.Owner == owner {
+			pos = i
+			break
+		}
+	}
+	// The ConfigMap is only written when joining the queue, taking the lock or when the expiration
+	// of the waiter is getting close, so that many waiters polling the same key mostly just read it.
+	update := false
+	if pos < 0 {
+		queue = append(queue, lockWaiter{Owner: owner, Key: key})
+		pos = len(queue) - 1
+		if head < 0 {
+			head = pos
+		}
+	}
+	if queue[pos].Expiration.Sub(now) < c.lockK8sLockTTL/2 {
+		queue[pos].Expiration = now.Add(c.lockK8sLockTTL)
+		update = true
+	}
+
+	var (
+		finalOwner string
+		lockErr    error
+	)
+	readers, err := c.parseReaderLocks(cm)
+	if err != nil {
+		return "", fmt.Errorf("failed to get shared locks from configmap: %v", err)
+	}
+
+	if reader := activeReader(readers, key, now); head == pos && reader != "" {
+		// We are next but the key is locked shared; wait for all the readers to release it
+		finalOwner, lockErr = reader, ErrConfigMapLocked
+	} else if head == pos {
+		finalOwner, lockErr = c.checkAndTakeLock(owner, key, false, lockIDs, lockExpirations)
+		if lockErr != nil && !errors.Is(lockErr, ErrConfigMapLocked) {
+			return finalOwner, lockErr
+		}
+		if lockErr == nil {
+			update = true
+			queue = append(queue[:pos], queue[pos+1:]...)
+			if err := c.generateConfigMapData(cm, lockIDs, lockExpirations); err != nil {
+				return finalOwner, err
+			}
+		}
+	} else {
+		// Somebody else is ahead of us in the queue
+		finalOwner, lockErr = queue[head].Owner, ErrConfigMapLocked
+		if currentOwner := lockIDs[key]; currentOwner != "" && now.Before(lockExpirations[key]) {
+			finalOwner = currentOwner
+		}
+	}
+
+	if !update {
+		return finalOwner, lockErr
+	}
+	if err := c.generateLockQueueData(cm, queue); err != nil {
+		return finalOwner, err
+	}
+	if _, err := c.updateConfigMap(cm); err != nil {
+		return "", err
+	}
+	return finalOwner, lockErr
+}
+
+// leaveLockQueue removes the given owner from the waiter queue of the key.
+func (c *configMap) leaveLockQueue(owner, key string) error {
+	var (
+		err error
+		cm  *v1.ConfigMap
+	)
+	for retries := 0; retries < maxConflictRetries; retries++ {
+		cm, err = c.ops().GetConfigMap(
+			c.name,
+			c.namespace,
+		)
+		if err != nil {
+			return err
+		}
+
+		queue, parseErr := c.parseLockQueue(cm)
+		if parseErr != nil {
+			return fmt.Errorf("failed to get lock queue from configmap: %v", parseErr)
+		}
+
+		newQueue := make([]lockWaiter, 0, len(queue))
+		for _, w := range queue {
+			if w.Owner != owner || w.Key != key {
+				newQueue = append(newQueue, w)
+			}
+		}
+		if len(newQueue) == len(queue) {
+			return nil
+		}
+		if err = c.generateLockQueueData(cm, newQueue); err != nil {
+			return err
+		}
+
+		var k8sConflict bool
+		if k8sConflict, err = c.updateConfigMap(cm); k8sConflict {
+			// try again
+			continue
+		}
+		return err
+	}
+	return err
+}
+
+// parseLockQueue reads the waiter queue from the given ConfigMap.
+func (c *configMap) parseLockQueue(cm *v1.ConfigMap) ([]lockWaiter, error) {
+	queue := []lockWaiter{}
+	if data, ok := cm.Data[pxLockQueueKey]; ok && len(data) > 0 {
+		if err := json.Unmarshal([]byte(data), &queue); err != nil {
+			return nil, err
+		}
+	}
+	return queue, nil
+}
+
+// generateLockQueueData converts the given waiter queue to JSON and stores it in the given ConfigMap.
+func (c *configMap) generateLockQueueData(cm *v1.ConfigMap, queue []lockWaiter) error {
+	if len(queue) == 0 {
+		delete(cm.Data, pxLockQueueKey)
+		return nil
+	}
+
+	data, err := json.Marshal(queue)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[pxLockQueueKey] = string(data)
+	return nil
+}
+
+func removeExpiredWaiters(queue []lockWaiter, now time.Time) []lockWaiter {
+	alive := make([]lockWaiter, 0, len(queue))
+	for _, w := range queue {
+		if now.Before(w.Expiration) {
+			alive = append(alive, w)
+		}
+	}
+	return alive
+}
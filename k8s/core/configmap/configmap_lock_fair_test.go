@@ -0,0 +1,101 @@
+package configmap
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	coreops "github.com/portworx/sched-ops/k8s/core"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFairLockOrder(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-fair-lock-test", nil, testLockTimeout, 20, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	cm.SetFairLocking(true)
+
+	key := "fair-lock-key"
+	err = cm.LockWithKey("fair-lock-id0", key)
+	require.NoError(t, err, "Unexpected error in LockWithKey(id0,key)")
+
+	var (
+		orderLock sync.Mutex
+		order     []string
+		wg        sync.WaitGroup
+	)
+	waiters := []string{"fair-lock-id1", "fair-lock-id2", "fair-lock-id3"}
+	errChan := make(chan error, 2*len(waiters))
+	for _, id := range waiters {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := cm.LockWithKey(id, key); err != nil {
+				errChan <- fmt.Errorf("LockWithKey(%s,key): %v", id, err)
+				return
+			}
+
+			orderLock.Lock()
+			order = append(order, id)
+			orderLock.Unlock()
+
+			time.Sleep(100 * time.Millisecond)
+			if err := cm.UnlockWithKey(key); err != nil {
+				errChan <- fmt.Errorf("UnlockWithKey(key) by %s: %v", id, err)
+			}
+		}(id)
+		// give the waiter time to join the queue before the next one
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	queue := getLockQueue(t, cm)
+	require.Len(t, queue, len(waiters))
+
+	require.NoError(t, cm.UnlockWithKey(key))
+	wg.Wait()
+	close(errChan)
+	for err := range errChan {
+		require.NoError(t, err)
+	}
+	require.Equal(t, waiters, order, "lock should be granted in arrival order")
+	require.Empty(t, getLockQueue(t, cm))
+}
+
+func TestFairLockExpiredWaiter(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-fair-lock-expired-test", nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	cm.SetFairLocking(true)
+
+	key := "fair-lock-key"
+	rawCM, err := coreops.Instance().GetConfigMap(cm.(*configMap).name, k8sSystemNamespace)
+	require.NoError(t, err)
+	err = cm.(*configMap).generateLockQueueData(rawCM, []lockWaiter{
+		{Owner: "dead-waiter", Key: key, Expiration: time.Now().Add(-time.Second)},
+	})
+	require.NoError(t, err)
+	_, err = coreops.Instance().UpdateConfigMap(rawCM)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = cm.LockWithKey("fair-lock-id1", key)
+	require.NoError(t, err, "waiter that stopped heartbeating should not block the lock")
+	require.Less(t, time.Since(start), lockSleepDuration)
+	require.Empty(t, getLockQueue(t, cm))
+	require.NoError(t, cm.UnlockWithKey(key))
+}
+
+func getRawConfigMap(t *testing.T, cm ConfigMap) *corev1.ConfigMap {
+	rawCM, err := coreops.Instance().GetConfigMap(cm.(*configMap).name, k8sSystemNamespace)
+	require.NoError(t, err)
+	return rawCM
+}
+
+func getLockQueue(t *testing.T, cm ConfigMap) []lockWaiter {
+	rawCM := getRawConfigMap(t, cm)
+	queue, err := cm.(*configMap).parseLockQueue(rawCM)
+	require.NoError(t, err)
+	return queue
+}
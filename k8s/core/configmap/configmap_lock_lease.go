@@ -0,0 +1,280 @@
+package configmap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// leaseLockKeyAnnotation is the annotation on the Lease objects which stores the locked key
+	leaseLockKeyAnnotation = "px-lock-key"
+	// maxLeaseNameLength is the maximum length of the name of a Lease object
+	maxLeaseNameLength = 253
+)
+
+// tryRefresh extends the expiration of the v2 lock held by owner in the configured lock backend.
+func (c *configMap) tryRefresh(owner, key string) error {
+	switch c.lockBackend {
+	case LockBackendLease:
+		_, err := c.tryLockLease(owner, key, true)
+		return err
+	case LockBackendMigrating:
+		if _, err := c.tryLock(owner, key, true); err != nil {
+			return err
+		}
+		_, err := c.tryLockLease(owner, key, true)
+		return err
+	}
+	_, err := c.tryLock(owner, key, true)
+	return err
+}
+
+// releaseLock releases the v2 lock held by owner in the configured lock backend.
+func (c *configMap) releaseLock(owner, key string) error {
+	switch c.lockBackend {
+	case LockBackendLease:
+		return c.releaseLeaseLock(owner, key)
+	case LockBackendMigrating:
+		// release in the reverse order of tryLockMigrating
+		if err := c.releaseLeaseLock(owner, key); err != nil {
+			return err
+		}
+	}
+	return c.releaseConfigMapLock(owner, key)
+}
+
+// getLockHolder returns the owner and expiration of the v2 lock on the given key from the configured
+// lock backend. The owner is empty if nobody holds the lock.
+func (c *configMap) getLockHolder(key string) (string, time.Time, error) {
+	switch c.lockBackend {
+	case LockBackendLease:
+		return c.getLeaseLockHolder(key)
+	case LockBackendMigrating:
+		// Users that have not migrated yet only lock the ConfigMap
+		owner, expiration, err := c.getConfigMapLockHolder(key)
+		if err != nil || owner != "" {
+			return owner, expiration, err
+		}
+		return c.getLeaseLockHolder(key)
+	}
+	return c.getConfigMapLockHolder(key)
+}
+
+// tryLockMigrating takes the v2 lock in the ConfigMap first and then in the Lease. If the Lease
+// cannot be taken, the ConfigMap lock is released so that we never hold only one of them.
+func (c *configMap) tryLockMigrating(owner, key string) (string, error) {
+	var (
+		currentOwner string
+		err          error
+	)
+	if c.fairLocking.Load() {
+		currentOwner, err = c.tryLockFair(owner, key)
+	} else {
+		currentOwner, err = c.tryLock(owner, key, false)
+	}
+	if err != nil {
+		return currentOwner, err
+	}
+
+	if currentOwner, err = c.tryLockLease(owner, key, false); err != nil {
+		if releaseErr := c.releaseConfigMapLock(owner, key); releaseErr != nil {
+			configMapLog("tryLockMigrating", c.name, owner, key, releaseErr).Warnf(
+				"Failed to release the ConfigMap lock after failing to take the Lease")
+		}
+		return currentOwner, err
+	}
+	return owner, nil
+}
+
+// tryLockLease checks if we can take the desired lock (refresh=false) or extend the expiration of the
+// lock we have taken already (refresh=true) in the Lease object of the key.
+func (c *configMap) tryLockLease(owner, key string, refresh bool) (string, error) {
+	fn := "tryLockLease"
+	now := meta_v1.NewMicroTime(time.Now())
+	ttlSeconds := int32(c.lockK8sLockTTL.Round(time.Second) / time.Second)
+	if ttlSeconds < 1 {
+		ttlSeconds = 1
+	}
+
+	lease, err := c.ops().GetLease(c.leaseName(key), c.namespace)
+	if k8s_errors.IsNotFound(err) {
+		if refresh {
+			configMapLog(fn, c.name, "", "", nil).Warnf(
+				"Lost our lock on key %s; lease %s was deleted", key, c.leaseName(key))
+			return "", ErrConfigMapLockLost
+		}
+		lease = &coordinationv1.Lease{
+			ObjectMeta: c.objectMeta(c.leaseName(key)),
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &owner,
+				LeaseDurationSeconds: &ttlSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		lease.Annotations[leaseLockKeyAnnotation] = key
+		if _, err := c.ops().CreateLease(lease); err != nil {
+			if k8s_errors.IsAlreadyExists(err) {
+				// somebody else created it first
+				return "", ErrConfigMapLocked
+			}
+			return "", err
+		}
+		return owner, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	currentOwner, expiration := leaseHolder(lease)
+	if refresh {
+		if currentOwner != owner {
+			configMapLog(fn, c.name, "", "", nil).Warnf(
+				"Lost our lock on key %s in lease %s to a new owner %q", key, lease.Name, currentOwner)
+			return currentOwner, ErrConfigMapLockLost
+		}
+	} else if currentOwner != "" && time.Now().Before(expiration) &&
+		!c.ifRequesterIsLockOwnerWithoutGoroutine(owner, currentOwner, key) {
+		return currentOwner, ErrConfigMapLocked
+	} else if currentOwner != owner {
+		if currentOwner != "" {
+			configMapLog(fn, c.name, owner, key, nil).Infof(
+				"Lock from owner '%s' is expired, now claiming for new owner '%s'", currentOwner, owner)
+		}
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.HolderIdentity = &owner
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = &transitions
+	}
+
+	lease.Spec.RenewTime = &now
+	lease.Spec.LeaseDurationSeconds = &ttlSeconds
+	if _, err := c.ops().UpdateLease(lease); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// releaseLeaseLock deletes the Lease of the given key if it is held by owner, so that the keys that
+// are locked only once, e.g. per volume, do not leave a Lease object behind. The Lease is only deleted
+// if it was not updated since it was read, in case another owner took the lock in the meantime.
+func (c *configMap) releaseLeaseLock(owner, key string) error {
+	fn := "releaseLeaseLock"
+	var err error
+	for retries := 0; retries < maxConflictRetries; retries++ {
+		var lease *coordinationv1.Lease
+		lease, err = c.ops().GetLease(c.leaseName(key), c.namespace)
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if currentOwner, _ := leaseHolder(lease); currentOwner != owner {
+			return nil
+		}
+		if err = c.ops().DeleteLeaseIfUnchanged(lease); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return nil
+			}
+			configMapLog(fn, c.name, owner, key, err).Errorf("Failed to delete lease during unlock")
+			if k8s_errors.IsConflict(err) {
+				// try unlocking again
+				continue
+			}
+			return err
+		}
+		return nil
+	}
+	return err
+}
+
+// deleteLeases deletes the Lease objects of all the keys of the configMap.
+func (c *configMap) deleteLeases() error {
+	leases, err := c.listLeases()
+	if err != nil {
+		return err
+	}
+	for _, lease := range leases {
+		if err := c.ops().DeleteLease(lease.Name, c.namespace); err != nil && !k8s_errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete lease %s: %w", lease.Name, err)
+		}
+	}
+	return nil
+}
+
+// listLeases returns the Lease objects of the keys of the configMap.
+func (c *configMap) listLeases() ([]*coordinationv1.Lease, error) {
+	leaseList, err := c.ops().ListLeases(c.namespace, meta_v1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(map[string]string{configMapUserLabelKey: TruncateLabel(c.name)}).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+
+	var leases []*coordinationv1.Lease
+	for i := range leaseList.Items {
+		lease := &leaseList.Items[i]
+		key, ok := lease.Annotations[leaseLockKeyAnnotation]
+		if !ok || lease.Name != c.leaseName(key) {
+			// the label is truncated; this lease belongs to another configMap
+			continue
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// getLeaseLockHolder returns the owner and expiration of the Lease of the given key. The owner is
+// empty if nobody holds the lock.
+func (c *configMap) getLeaseLockHolder(key string) (string, time.Time, error) {
+	lease, err := c.ops().GetLease(c.leaseName(key), c.namespace)
+	if k8s_errors.IsNotFound(err) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	owner, expiration := leaseHolder(lease)
+	return owner, expiration, nil
+}
+
+// leaseName returns the name of the Lease object for the given key. The name is made of the sanitized
+// ConfigMap name and key, followed by a hash of the key so that different keys never share a Lease.
+func (c *configMap) leaseName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:10]
+	prefix := strings.ToLower(configMapNameRegex.ReplaceAllString(c.name+"-"+key, "-"))
+	prefix = strings.Trim(prefix, "-")
+	if maxPrefix := maxLeaseNameLength - len(hash) - 1; len(prefix) > maxPrefix {
+		prefix = strings.TrimRight(prefix[:maxPrefix], "-")
+	}
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+// leaseHolder returns the holder of the given Lease and the time at which its lock expires.
+func leaseHolder(lease *coordinationv1.Lease) (string, time.Time) {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return "", time.Time{}
+	}
+	var expiration time.Time
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		expiration = lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	}
+	return *lease.Spec.HolderIdentity, expiration
+}
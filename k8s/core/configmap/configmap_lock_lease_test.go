@@ -0,0 +1,129 @@
+package configmap
+
+import (
+	"strings"
+	"testing"
+
+	coreops "github.com/portworx/sched-ops/k8s/core"
+	"github.com/stretchr/testify/require"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestLockBackendLease(t *testing.T) {
+	setUpFakeConfigMapClient()
+	name := "px-configmaps-lease-backend-test"
+	cm1, err := New(name, nil, testLockTimeout, testLockAttempts, testLockRefreshDuration, testLockTTL,
+		WithLockBackend(LockBackendLease))
+	require.NoError(t, err, "Unexpected error on New")
+	cm2, err := New(name, nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL,
+		WithLockBackend(LockBackendLease))
+	require.NoError(t, err, "Unexpected error on New")
+
+	id1 := "lease-backend-id1"
+	id2 := "lease-backend-id2"
+	key1 := "lease-backend-key1"
+
+	err = cm1.LockWithKey(id1, key1)
+	require.NoError(t, err, "Unexpected error in LockWithKey(id1,key1)")
+
+	lease, err := coreops.Instance().GetLease(cm1.(*configMap).leaseName(key1), k8sSystemNamespace)
+	require.NoError(t, err, "Lease object was not created")
+	require.Equal(t, id1, *lease.Spec.HolderIdentity)
+	require.Equal(t, key1, lease.Annotations[leaseLockKeyAnnotation])
+
+	// the lock must not be stored in the ConfigMap
+	owner, _, err := cm1.(*configMap).getConfigMapLockHolder(key1)
+	require.NoError(t, err)
+	require.Empty(t, owner)
+
+	locked, owner, err := cm2.IsKeyLocked(key1, id2)
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.Equal(t, id1, owner)
+
+	err = cm2.LockWithKey(id2, key1)
+	require.ErrorIs(t, err, ErrConfigMapLocked, "Expected LockWithKey(id2,key1) to fail")
+
+	require.NoError(t, cm1.PatchKeyLocked(false, id1, key1, "val1"))
+	require.Error(t, cm2.PatchKeyLocked(false, id2, key1, "val2"))
+
+	err = cm2.RLockWithKey(id2, key1)
+	require.Error(t, err, "Expected shared locks to be unsupported")
+
+	require.NoError(t, cm1.UnlockWithKey(key1))
+	locked, _, err = cm2.IsKeyLocked(key1, id2)
+	require.NoError(t, err)
+	require.False(t, locked)
+	_, err = coreops.Instance().GetLease(cm1.(*configMap).leaseName(key1), k8sSystemNamespace)
+	require.True(t, k8s_errors.IsNotFound(err), "Expected the Lease to be deleted on unlock")
+
+	err = cm2.LockWithKey(id2, key1)
+	require.NoError(t, err, "Unexpected error in LockWithKey(id2,key1)")
+	lease, err = coreops.Instance().GetLease(cm2.(*configMap).leaseName(key1), k8sSystemNamespace)
+	require.NoError(t, err)
+	require.Equal(t, id2, *lease.Spec.HolderIdentity)
+
+	// deleting the configMap deletes the Leases of its keys
+	require.NoError(t, cm2.Delete())
+	_, err = coreops.Instance().GetLease(cm2.(*configMap).leaseName(key1), k8sSystemNamespace)
+	require.True(t, k8s_errors.IsNotFound(err), "Expected the Lease to be deleted with the configMap")
+	require.NoError(t, cm2.UnlockWithKey(key1))
+}
+
+func TestLockBackendMigrating(t *testing.T) {
+	setUpFakeConfigMapClient()
+	name := "px-configmaps-lease-migrating-test"
+	cmOld, err := New(name, nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	cmMigrating, err := New(name, nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL,
+		WithLockBackend(LockBackendMigrating))
+	require.NoError(t, err, "Unexpected error on New")
+	cmNew, err := New(name, nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL,
+		WithLockBackend(LockBackendLease))
+	require.NoError(t, err, "Unexpected error on New")
+
+	idOld := "migrating-id-old"
+	idMigrating := "migrating-id-migrating"
+	idNew := "migrating-id-new"
+	key1 := "migrating-key1"
+
+	// a migrating owner excludes owners on both of the other backends
+	require.NoError(t, cmMigrating.LockWithKey(idMigrating, key1))
+	owner, _, err := cmMigrating.(*configMap).getConfigMapLockHolder(key1)
+	require.NoError(t, err)
+	require.Equal(t, idMigrating, owner)
+	owner, _, err = cmMigrating.(*configMap).getLeaseLockHolder(key1)
+	require.NoError(t, err)
+	require.Equal(t, idMigrating, owner)
+
+	require.ErrorIs(t, cmOld.LockWithKey(idOld, key1), ErrConfigMapLocked)
+	require.ErrorIs(t, cmNew.LockWithKey(idNew, key1), ErrConfigMapLocked)
+	require.NoError(t, cmMigrating.UnlockWithKey(key1))
+
+	// an owner not migrated yet excludes migrating owners
+	require.NoError(t, cmOld.LockWithKey(idOld, key1))
+	require.ErrorIs(t, cmMigrating.LockWithKey(idMigrating, key1), ErrConfigMapLocked)
+	locked, owner, err := cmMigrating.IsKeyLocked(key1, idMigrating)
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.Equal(t, idOld, owner)
+	require.NoError(t, cmOld.UnlockWithKey(key1))
+
+	// a migrated owner excludes migrating owners, which must not keep the ConfigMap lock
+	require.NoError(t, cmNew.LockWithKey(idNew, key1))
+	require.ErrorIs(t, cmMigrating.LockWithKey(idMigrating, key1), ErrConfigMapLocked)
+	owner, _, err = cmMigrating.(*configMap).getConfigMapLockHolder(key1)
+	require.NoError(t, err)
+	require.Empty(t, owner)
+	require.NoError(t, cmNew.UnlockWithKey(key1))
+}
+
+func TestLeaseName(t *testing.T) {
+	c := &configMap{name: "px-configmaps-lease-name"}
+	require.NotEqual(t, c.leaseName("a_b"), c.leaseName("a.b"), "Keys must not share a lease")
+	require.Equal(t, c.leaseName("key1"), c.leaseName("key1"))
+
+	long := c.leaseName(strings.Repeat("Key", 200))
+	require.LessOrEqual(t, len(long), maxLeaseNameLength)
+	require.Equal(t, strings.ToLower(long), long)
+}
@@ -0,0 +1,308 @@
+package configmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// readerLockID identifies a shared lock held by this process. Unlike exclusive locks, many
+// owners can hold a shared lock on the same key.
+type readerLockID struct {
+	key   string
+	owner string
+}
+
+func (c *configMap) RLockWithKey(owner, key string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+	if owner == "" {
+		return fmt.Errorf("owner cannot be empty")
+	}
+	if c.lockBackend != LockBackendConfigMap {
+		return fmt.Errorf("shared locks are not supported with the %q lock backend", c.lockBackend)
+	}
+
+	fn := "RLockWithKey"
+	configMapLog(fn, c.name, owner, key, nil).Debugf("Taking the shared lock")
+
+	count := uint(0)
+	// try acquiring a shared lock on the ConfigMap
+	writer, err := c.tryRLock(owner, key, false)
+	// if it fails, keep trying for the provided number of retries until it succeeds
+	for maxCount := c.lockAttempts; err != nil && count < maxCount; count++ {
+		time.Sleep(lockSleepDuration)
+		writer, err = c.tryRLock(owner, key, false)
+		if count > 0 && count%15 == 0 && err != nil {
+			configMapLog(fn, c.name, writer, key, err).Warnf("Locked for"+
+				" %v seconds", float64(count)*lockSleepDuration.Seconds())
+		}
+	}
+	if err != nil {
+		// We failed to acquire the lock
+		return err
+	}
+	if count >= 30 {
+		configMapLog(fn, c.name, owner, key, err).Warnf("Spent %v iteration"+
+			" locking.", count)
+	}
+
+	id := readerLockID{key: key, owner: owner}
+	c.kRLocksMutex.Lock()
+	oldLock := c.kRLocks[id]
+	lock := &k8sLock{done: make(chan struct{}), id: owner}
+	c.kRLocks[id] = lock
+	c.kRLocksMutex.Unlock()
+	if oldLock != nil {
+		// Same as LockWithKey: make sure the refresh goroutine of a lost lock does not
+		// interfere with the new one
+		oldLock.Lock()
+		if !oldLock.unlocked {
+			close(oldLock.done)
+			oldLock.unlocked = true
+		}
+		oldLock.Unlock()
+	}
+
+	configMapLog(fn, c.name, owner, key, nil).Debugf("Starting shared lock refresh")
+	go c.runRefreshLoop(lock, owner, key, func() error {
+		_, err := c.tryRLock(owner, key, true)
+		return err
+	})
+	return nil
+}
+
+func (c *configMap) RUnlockWithKey(owner, key string) error {
+	if key == "" {
+		return fmt.Errorf("key cannot be empty")
+	}
+
+	fn := "RUnlockWithKey"
+	configMapLog(fn, c.name, owner, key, nil).Debugf("Releasing the shared lock for %s", key)
+
+	id := readerLockID{key: key, owner: owner}
+	c.kRLocksMutex.Lock()
+	lock, ok := c.kRLocks[id]
+	c.kRLocksMutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if lock.unlocked {
+		// The lock is already unlocked. Chan cannot be closed again. Return immediately.
+		return nil
+	}
+	lock.unlocked = true
+	close(lock.done)
+
+	var (
+		err error
+		cm  *v1.ConfigMap
+	)
+	for retries := 0; retries < maxConflictRetries; retries++ {
+		cm, err = c.ops().GetConfigMap(
+			c.name,
+			c.namespace,
+		)
+		if err != nil {
+			// A ConfigMap should always be created.
+			return err
+		}
+
+		readers, parseErr := c.parseReaderLocks(cm)
+		if parseErr != nil {
+			return fmt.Errorf("failed to get shared locks from configmap: %v", parseErr)
+		}
+		if _, ok := readers[key][owner]; !ok {
+			return nil
+		}
+		delete(readers[key], owner)
+
+		if err = c.generateReaderLockData(cm, readers); err != nil {
+			return err
+		}
+		var k8sConflict bool
+		if k8sConflict, err = c.updateConfigMap(cm); err != nil {
+			configMapLog(fn, c.name, "", "", err).Errorf("Failed to update" +
+				" config map during shared unlock")
+			if k8sConflict {
+				// try unlocking again
+				continue
+			}
+			// else unknown error - return immediately
+			return err
+		}
+
+		c.kRLocksMutex.Lock()
+		delete(c.kRLocks, id)
+		c.kRLocksMutex.Unlock()
+		return nil
+	}
+
+	return err
+}
+
+// tryRLock takes the shared lock for the given owner if no exclusive lock is held on the key and
+// no writer is waiting for it in the fair lock queue (refresh=false), or extends the expiration of the shared lock already held (refresh=true).
+// It returns the owner of the exclusive lock, or of the first waiting writer, if the key is locked.
+func (c *configMap) tryRLock(owner, key string, refresh bool) (string, error) {
+	fn := "tryRLock"
+	cm, err := c.ops().GetConfigMap(
+		c.name,
+		c.namespace,
+	)
+	if err != nil {
+		// A ConfigMap should always be created.
+		return "", err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+
+	lockOwners, lockExpirations, err := c.parseLocks(cm)
+	if err != nil {
+		return "", fmt.Errorf("failed to get locks from configmap: %v", err)
+	}
+	readers, err := c.parseReaderLocks(cm)
+	if err != nil {
+		return "", fmt.Errorf("failed to get shared locks from configmap: %v", err)
+	}
+
+	now := time.Now()
+	if refresh {
+		if _, ok := readers[key][owner]; !ok {
+			configMapLog(fn, c.name, owner, key, nil).Warnf(
+				"Lost our shared lock on key %s in the configMap %s", key, c.name)
+			return "", ErrConfigMapLockLost
+		}
+	} else {
+		if writer := lockOwners[key]; writer != "" && now.Before(lockExpirations[key]) {
+			return writer, ErrConfigMapLocked
+		}
+		// Writers waiting in the fair lock queue go first, so that a steady flow of readers
+		// cannot starve them
+		queue, err := c.parseLockQueue(cm)
+		if err != nil {
+			return "", fmt.Errorf("failed to get lock queue from configmap: %v", err)
+		}
+		for _, w := range removeExpiredWaiters(queue, now) {
+			if w.Key == key {
+				return w.Owner, ErrConfigMapLocked
+			}
+		}
+		if c.isReaderLockHeld(owner, key) {
+			// shared locks are non-reentrant too
+			return owner, ErrConfigMapLocked
+		}
+	}
+
+	for reader, expiration := range readers[key] {
+		if !now.Before(expiration) {
+			configMapLog(fn, c.name, reader, key, nil).Infof("Shared lock from owner '%s' is expired", reader)
+			delete(readers[key], reader)
+		}
+	}
+	if readers[key] == nil {
+		readers[key] = map[string]time.Time{}
+	}
+	readers[key][owner] = now.Add(c.lockK8sLockTTL)
+
+	if err := c.generateReaderLockData(cm, readers); err != nil {
+		return "", err
+	}
+	if _, err := c.updateConfigMap(cm); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// isReaderLockHeld returns true if this process holds the shared lock for the owner and key.
+func (c *configMap) isReaderLockHeld(owner, key string) bool {
+	c.kRLocksMutex.Lock()
+	lock := c.kRLocks[readerLockID{key: key, owner: owner}]
+	c.kRLocksMutex.Unlock()
+	if lock == nil {
+		return false
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	return !lock.unlocked
+}
+
+// parseReaderLocks reads the shared lock data from the given ConfigMap and converts it to a map
+// of keys to the owners holding a shared lock on the key and their lock expiration times.
+func (c *configMap) parseReaderLocks(cm *v1.ConfigMap) (map[string]map[string]time.Time, error) {
+	parsedLocks, err := readLockData(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	readers := map[string]map[string]time.Time{}
+	for _, lock := range parsedLocks {
+		if !lock.Shared {
+			continue
+		}
+		if readers[lock.Key] == nil {
+			readers[lock.Key] = map[string]time.Time{}
+		}
+		readers[lock.Key][lock.Owner] = lock.Expiration
+	}
+	return readers, nil
+}
+
+// generateReaderLockData converts the given shared lock data to JSON and stores it in the given
+// ConfigMap. The exclusive locks already present in the ConfigMap are kept.
+func (c *configMap) generateReaderLockData(cm *v1.ConfigMap, readers map[string]map[string]time.Time) error {
+	existingLocks, err := readLockData(cm)
+	if err != nil {
+		return err
+	}
+
+	var locks []lockData
+	for _, lock := range existingLocks {
+		if !lock.Shared {
+			locks = append(locks, lock)
+		}
+	}
+	for key, owners := range readers {
+		for owner, expiration := range owners {
+			locks = append(locks, lockData{
+				Owner:      owner,
+				Key:        key,
+				Expiration: expiration,
+				Shared:     true,
+			})
+		}
+	}
+
+	cmData, err := json.Marshal(locks)
+	if err != nil {
+		return err
+	}
+	cm.Data[pxLockKey] = string(cmData)
+	return nil
+}
+
+// activeReader returns one of the owners holding an unexpired shared lock on the given key, or
+// an empty string if there is none.
+func activeReader(readers map[string]map[string]time.Time, key string, now time.Time) string {
+	var owners []string
+	for owner, expiration := range readers[key] {
+		if now.Before(expiration) {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == 0 {
+		return ""
+	}
+	sort.Strings(owners)
+	return owners[0]
+}
@@ -0,0 +1,97 @@
+package configmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRWLock(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-rw-lock-test", nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	// the writer uses its own instance so that it keeps waiting for the readers
+	cmWriter, err := New("px-configmaps-rw-lock-test", nil, testLockTimeout, 20, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+
+	reader1 := "rw-lock-reader1"
+	reader2 := "rw-lock-reader2"
+	writer := "rw-lock-writer"
+	key := "rw-lock-key"
+
+	// many readers can hold the key at the same time
+	require.NoError(t, cm.RLockWithKey(reader1, key))
+	require.NoError(t, cm.RLockWithKey(reader2, key))
+
+	// shared locks are non-reentrant
+	err = cm.RLockWithKey(reader1, key)
+	require.ErrorIs(t, err, ErrConfigMapLocked)
+
+	// a shared lock does not allow updates
+	err = cm.PatchKeyLocked(false, reader1, key, "bar")
+	require.ErrorContains(t, err, "exclusive lock is required")
+
+	// a writer waits for all the readers
+	writerLocked := make(chan error)
+	go func() {
+		writerLocked <- cmWriter.LockWithKey(writer, key)
+	}()
+	time.Sleep(2 * lockSleepDuration)
+	require.NoError(t, cm.RUnlockWithKey(reader1, key))
+	select {
+	case <-writerLocked:
+		t.Fatal("writer should not get the lock while a reader holds it")
+	case <-time.After(lockSleepDuration / 2):
+	}
+	require.NoError(t, cm.RUnlockWithKey(reader2, key))
+	require.NoError(t, <-writerLocked)
+
+	locked, owner, err := cm.IsKeyLocked(key, reader1)
+	require.NoError(t, err)
+	require.True(t, locked)
+	require.Equal(t, writer, owner)
+
+	// readers wait for the writer
+	err = cm.RLockWithKey(reader1, key)
+	require.ErrorIs(t, err, ErrConfigMapLocked)
+
+	require.NoError(t, cmWriter.PatchKeyLocked(false, writer, key, "bar"))
+	require.NoError(t, cmWriter.UnlockWithKey(key))
+
+	require.NoError(t, cm.RLockWithKey(reader1, key))
+	// the shared lock survives refreshes
+	time.Sleep(2 * testLockRefreshDuration)
+	readers, err := cm.(*configMap).parseReaderLocks(getRawConfigMap(t, cm))
+	require.NoError(t, err)
+	require.Contains(t, readers[key], reader1)
+	require.NoError(t, cm.RUnlockWithKey(reader1, key))
+}
+
+func TestRWLockWaitingWriter(t *testing.T) {
+	setUpFakeConfigMapClient()
+	cm, err := New("px-configmaps-rw-lock-waiting-test", nil, testLockTimeout, 1, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	cmWriter, err := New("px-configmaps-rw-lock-waiting-test", nil, testLockTimeout, 20, testLockRefreshDuration, testLockTTL)
+	require.NoError(t, err, "Unexpected error on New")
+	cmWriter.SetFairLocking(true)
+
+	key := "rw-lock-key"
+	require.NoError(t, cm.RLockWithKey("rw-lock-reader1", key))
+
+	writerLocked := make(chan error)
+	go func() {
+		writerLocked <- cmWriter.LockWithKey("rw-lock-writer", key)
+	}()
+	time.Sleep(2 * lockSleepDuration)
+
+	// new readers do not get ahead of the queued writer
+	err = cm.RLockWithKey("rw-lock-reader2", key)
+	require.ErrorIs(t, err, ErrConfigMapLocked)
+
+	require.NoError(t, cm.RUnlockWithKey("rw-lock-reader1", key))
+	require.NoError(t, <-writerLocked)
+	require.NoError(t, cmWriter.UnlockWithKey(key))
+	require.NoError(t, cm.RLockWithKey("rw-lock-reader2", key))
+	require.NoError(t, cm.RUnlockWithKey("rw-lock-reader2", key))
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"time"
 
-	"github.com/portworx/sched-ops/k8s/core"
 	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 )
@@ -71,9 +70,9 @@ func (c *configMap) Unlock() error {
 		cm  *corev1.ConfigMap
 	)
 	for retries := 0; retries < maxConflictRetries; retries++ {
-		cm, err = core.Instance().GetConfigMap(
+		cm, err = c.ops().GetConfigMap(
 			c.name,
-			k8sSystemNamespace,
+			c.namespace,
 		)
 		if err != nil {
 			// A ConfigMap should always be created.
@@ -87,7 +86,7 @@ func (c *configMap) Unlock() error {
 		}
 		delete(cm.Data, pxOwnerKey)
 		delete(cm.Data, pxExpirationKey)
-		if _, err = core.Instance().UpdateConfigMap(cm); err != nil {
+		if _, err = c.ops().UpdateConfigMap(cm); err != nil {
 			configMapLog(fn, c.name, "", "", err).Errorf("Failed to update" +
 				" config map during unlock")
 			if k8s_errors.IsConflict(err) {
@@ -105,9 +104,9 @@ func (c *configMap) Unlock() error {
 func (c *configMap) tryLockV1(id string, refresh bool) (string, error) {
 	fn := "tryLockV1"
 	// Get the existing ConfigMap
-	cm, err := core.Instance().GetConfigMap(
+	cm, err := c.ops().GetConfigMap(
 		c.name,
-		k8sSystemNamespace,
+		c.namespace,
 	)
 	if err != nil {
 		// A ConfigMap should always be created.
@@ -151,7 +150,7 @@ func (c *configMap) tryLockV1(id string, refresh bool) (string, error) {
 	// Take the lock or increase our expiration if we are already holding the lock
 	cm.Data[pxOwnerKey] = id
 	cm.Data[pxExpirationKey] = time.Now().Add(v1DefaultK8sLockTTL).Format(time.UnixDate)
-	if _, err = core.Instance().UpdateConfigMap(cm); err != nil {
+	if _, err = c.ops().UpdateConfigMap(cm); err != nil {
 		return "", err
 	}
 	return id, nil
@@ -1,6 +1,7 @@
 package configmap
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,13 +11,45 @@ import (
 	v1 "k8s.io/api/core/v1"
 
 	"github.com/libopenstorage/openstorage/pkg/dbg"
-	"github.com/portworx/sched-ops/k8s/core"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 func (c *configMap) LockWithKey(owner, key string) error {
+	_, err := c.lockWithKey(context.Background(), owner, key, nil)
+	return err
+}
+
+func (c *configMap) LockWithKeyCtx(ctx context.Context, owner, key string) (Lease, error) {
+	// The lease outlives ctx only until the lock is released below, so that Done is not closed
+	// while the lock is still held and refreshed.
+	leaseCtx, cancel := context.WithCancelCause(context.Background())
+	lock, err := c.lockWithKey(ctx, owner, key, cancel)
+	if err != nil {
+		cancel(err)
+		return nil, err
+	}
+	l := &lease{
+		cm:    c,
+		owner: owner,
+		key:   key,
+		lock:  lock,
+		ctx:   leaseCtx,
+	}
+	stop := context.AfterFunc(ctx, func() {
+		if err := l.release(context.Cause(ctx)); err != nil {
+			configMapLog("LockWithKeyCtx", c.name, owner, key, err).Warn("Failed to release the lock of a done context")
+		}
+	})
+	context.AfterFunc(leaseCtx, func() { stop() })
+	return l, nil
+}
+
+// lockWithKey takes the v2 lock for the given key and starts the refresh goroutine. Waiting for the
+// lock is aborted when ctx is done. cancelLease is non-nil for locks taken through LockWithKeyCtx and
+// is called with the reason when the lock is lost or released.
+func (c *configMap) lockWithKey(ctx context.Context, owner, key string, cancelLease context.CancelCauseFunc) (*k8sLock, error) {
 	if key == "" {
-		return fmt.Errorf("key cannot be empty")
+		return nil, fmt.Errorf("key cannot be empty")
 	}
 
 	fn := "LockWithKey"
@@ -24,11 +57,16 @@ func (c *configMap) LockWithKey(owner, key string) error {
 
 	count := uint(0)
 	// try acquiring a lock on the ConfigMap
-	newOwner, err := c.tryLock(owner, key, false)
+	newOwner, err := c.tryAcquire(owner, key)
 	// if it fails, keep trying for the provided number of retries until it succeeds
 	for maxCount := c.lockAttempts; err != nil && count < maxCount; count++ {
-		time.Sleep(lockSleepDuration)
-		newOwner, err = c.tryLock(owner, key, false)
+		select {
+		case <-ctx.Done():
+			c.giveUpAcquire(owner, key)
+			return nil, ctx.Err()
+		case <-time.After(lockSleepDuration):
+		}
+		newOwner, err = c.tryAcquire(owner, key)
 		if count > 0 && count%15 == 0 && err != nil {
 			configMapLog(fn, c.name, newOwner, key, err).Warnf("Locked for"+
 				" %v seconds", float64(count)*lockSleepDuration.Seconds())
@@ -36,7 +74,8 @@ func (c *configMap) LockWithKey(owner, key string) error {
 	}
 	if err != nil {
 		// We failed to acquire the lock
-		return err
+		c.giveUpAcquire(owner, key)
+		return nil, err
 	}
 	if count >= 30 {
 		configMapLog(fn, c.name, newOwner, key, err).Warnf("Spent %v iteration"+
@@ -55,22 +94,28 @@ func (c *configMap) LockWithKey(owner, key string) error {
 			configMapLog(fn, c.name, newOwner, key, err).Warn("Found old lock still locked. Unlocking...")
 			close(oldLock.done)
 			oldLock.unlocked = true
+			oldLock.cancelLease(ErrConfigMapLockLost)
 		}
 		oldLock.Unlock()
 	}
 
 	// Create a new lock and store it
-	lock := &k8sLock{done: make(chan struct{}), id: owner}
+	lock := &k8sLock{done: make(chan struct{}), id: owner, cancel: cancelLease}
 	c.kLocksV2Mutex.Lock()
 	c.kLocksV2[key] = lock
 	c.kLocksV2Mutex.Unlock()
 
 	configMapLog(fn, c.name, owner, key, nil).Debugf("Starting lock refresh")
 	go c.refreshLock(owner, key)
-	return nil
+	return lock, nil
 }
 
 func (c *configMap) UnlockWithKey(key string) error {
+	return c.unlockWithKey(key, ErrConfigMapLockReleased)
+}
+
+// unlockWithKey releases the v2 lock on the given key, cancelling its Lease, if any, with the given cause.
+func (c *configMap) unlockWithKey(key string, cause error) error {
 	if key == "" {
 		return fmt.Errorf("key cannot be empty")
 	}
@@ -98,7 +143,22 @@ func (c *configMap) UnlockWithKey(key string) error {
 	// If we write to the chan, we may block indefinitely. Note that LockWithKey will also close the old chan if
 	// old lock is not yet unlocked.
 	close(lock.done)
+	lock.cancelLease(cause)
+
+	if err := c.releaseLock(lock.id, key); err != nil {
+		return err
+	}
+
+	// Clean up the lock
+	c.kLocksV2Mutex.Lock()
+	delete(c.kLocksV2, key)
+	c.kLocksV2Mutex.Unlock()
+	return nil
+}
 
+// releaseConfigMapLock removes the v2 lock on the given key from the ConfigMap if it is held by owner.
+func (c *configMap) releaseConfigMapLock(owner, key string) error {
+	fn := "UnlockWithKey"
 	var (
 		err error
 		cm  *v1.ConfigMap
@@ -106,9 +166,9 @@ func (c *configMap) UnlockWithKey(key string) error {
 
 	// Get the existing ConfigMap
 	for retries := 0; retries < maxConflictRetries; retries++ {
-		cm, err = core.Instance().GetConfigMap(
+		cm, err = c.ops().GetConfigMap(
 			c.name,
-			k8sSystemNamespace,
+			c.namespace,
 		)
 		if err != nil {
 			// A ConfigMap should always be created.
@@ -121,7 +181,7 @@ func (c *configMap) UnlockWithKey(key string) error {
 		}
 
 		currentOwner := lockOwners[key]
-		if currentOwner != lock.id {
+		if currentOwner != owner {
 			return nil
 		}
 
@@ -144,11 +204,6 @@ func (c *configMap) UnlockWithKey(key string) error {
 			// else unknown error - return immediately
 			return err
 		}
-
-		// Clean up the lock
-		c.kLocksV2Mutex.Lock()
-		delete(c.kLocksV2, key)
-		c.kLocksV2Mutex.Unlock()
 		return nil
 	}
 
@@ -158,29 +213,15 @@ func (c *configMap) UnlockWithKey(key string) error {
 // If the lock hasn't expired but the owner doesn't have a refresh goroutine,
 // we return unlocked for the owner but locked for others
 func (c *configMap) IsKeyLocked(key, requester string) (bool, string, error) {
-	// Get the existing ConfigMap
-	cm, err := core.Instance().GetConfigMap(
-		c.name,
-		k8sSystemNamespace,
-	)
+	owner, expiration, err := c.getLockHolder(key)
 	if err != nil {
 		return false, "", err
 	}
 
-	if cm.Data == nil {
-		cm.Data = make(map[string]string)
-	}
-
-	lockIDs, lockExpirations, err := c.parseLocks(cm)
-	if err != nil {
-		return false, "", fmt.Errorf("failed to get locks from configmap: %v", err)
-	}
-
-	if owner, ok := lockIDs[key]; ok {
+	if owner != "" {
 		// Existing key is unlocked if
 		//   1. Lock has expired; or
 		//   2. Lock owned by itself but refresh goroutine is not running
-		expiration := lockExpirations[key]
 		if time.Now().After(expiration) {
 			return false, "", nil
 		}
@@ -194,11 +235,30 @@ func (c *configMap) IsKeyLocked(key, requester string) (bool, string, error) {
 	return false, "", nil
 }
 
+// getConfigMapLockHolder returns the owner and expiration of the v2 lock on the given key stored in
+// the ConfigMap. The owner is empty if nobody holds the lock.
+func (c *configMap) getConfigMapLockHolder(key string) (string, time.Time, error) {
+	// Get the existing ConfigMap
+	cm, err := c.ops().GetConfigMap(
+		c.name,
+		c.namespace,
+	)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	lockIDs, lockExpirations, err := c.parseLocks(cm)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get locks from configmap: %v", err)
+	}
+	return lockIDs[key], lockExpirations[key], nil
+}
+
 func (c *configMap) tryLock(owner string, key string, refresh bool) (string, error) {
 	// Get the existing ConfigMap
-	cm, err := core.Instance().GetConfigMap(
+	cm, err := c.ops().GetConfigMap(
 		c.name,
-		k8sSystemNamespace,
+		c.namespace,
 	)
 	if err != nil {
 		// A ConfigMap should always be created.
@@ -214,6 +274,17 @@ func (c *configMap) tryLock(owner string, key string, refresh bool) (string, err
 		return "", fmt.Errorf("failed to get locks from configmap: %v", err)
 	}
 
+	if !refresh {
+		readers, err := c.parseReaderLocks(cm)
+		if err != nil {
+			return "", fmt.Errorf("failed to get shared locks from configmap: %v", err)
+		}
+		if reader := activeReader(readers, key, time.Now()); reader != "" {
+			// The key is locked shared; wait for all the readers to release it
+			return reader, ErrConfigMapLocked
+		}
+	}
+
 	finalOwner, err := c.checkAndTakeLock(owner, key, refresh, lockIDs, lockExpirations)
 	if err != nil {
 		return finalOwner, err
@@ -239,17 +310,15 @@ func (c *configMap) getV2LockOwnerIncludeExpired(cm *v1.ConfigMap, key string) (
 	return lockOwners[key], nil
 }
 
-// parseLocks reads the lock data from the given ConfigMap and then converts it to:
+// parseLocks reads the exclusive lock data from the given ConfigMap and then converts it to:
 // * a map of keys to lock owners
 // * a map of keys to lock expiration times
+// Shared locks are returned by parseReaderLocks.
 func (c *configMap) parseLocks(cm *v1.ConfigMap) (map[string]string, map[string]time.Time, error) {
 	// Check all the locks: will be an empty string if key is not present indicating no lock
-	parsedLocks := []lockData{}
-	if lock, ok := cm.Data[pxLockKey]; ok && len(lock) > 0 {
-		err := json.Unmarshal([]byte(cm.Data[pxLockKey]), &parsedLocks)
-		if err != nil {
-			return nil, nil, err
-		}
+	parsedLocks, err := readLockData(cm)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Check all the locks first and store them, makes the looping a little easier
@@ -257,6 +326,9 @@ func (c *configMap) parseLocks(cm *v1.ConfigMap) (map[string]string, map[string]
 	lockExpirations := map[string]time.Time{}
 
 	for _, lock := range parsedLocks {
+		if lock.Shared {
+			continue
+		}
 		lockOwners[lock.Key] = lock.Owner
 		lockExpirations[lock.Key] = lock.Expiration
 	}
@@ -264,6 +336,18 @@ func (c *configMap) parseLocks(cm *v1.ConfigMap) (map[string]string, map[string]
 	return lockOwners, lockExpirations, nil
 }
 
+// readLockData reads all the lockData entries, exclusive and shared, from the given ConfigMap.
+func readLockData(cm *v1.ConfigMap) ([]lockData, error) {
+	parsedLocks := []lockData{}
+	if lock, ok := cm.Data[pxLockKey]; ok && len(lock) > 0 {
+		err := json.Unmarshal([]byte(lock), &parsedLocks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parsedLocks, nil
+}
+
 // checkAndTakeLock checks if we can take the desired lock (refresh=false) or extend the expiration of the lock
 // we have taken already (refresh=true). If either condition is true, it updates the in-memory state in
 // lockOwners and lockExpirations. "refresh" argument indicates if this is the refreshLock goroutine refreshing
@@ -322,9 +406,19 @@ func (c *configMap) checkAndTakeLock(
 }
 
 // generateConfigMapData converts the given lock data (lockOwners, lockExpirations) to JSON and
-// stores it in the given ConfigMap.
+// stores it in the given ConfigMap. The shared locks already present in the ConfigMap are kept.
 func (c *configMap) generateConfigMapData(cm *v1.ConfigMap, lockOwners map[string]string, lockExpirations map[string]time.Time) error {
+	existingLocks, err := readLockData(cm)
+	if err != nil {
+		return err
+	}
+
 	var locks []lockData
+	for _, lock := range existingLocks {
+		if lock.Shared {
+			locks = append(locks, lock)
+		}
+	}
 	for key, lockOwner := range lockOwners {
 		locks = append(locks, lockData{
 			Owner:      lockOwner,
@@ -342,7 +436,7 @@ func (c *configMap) generateConfigMapData(cm *v1.ConfigMap, lockOwners map[strin
 }
 
 func (c *configMap) updateConfigMap(cm *v1.ConfigMap) (bool, error) {
-	if _, err := core.Instance().UpdateConfigMap(cm); err != nil {
+	if _, err := c.ops().UpdateConfigMap(cm); err != nil {
 		return k8s_errors.IsConflict(err), err
 	}
 	return false, nil
@@ -353,6 +447,27 @@ func (c *configMap) updateConfigMap(cm *v1.ConfigMap) (bool, error) {
 // node dies, the lock can have a short timeout and expire quickly but we can still
 // take longer-term locks.
 func (c *configMap) refreshLock(id, key string) {
+	// get a reference to the lock object so we don't have to hold open a
+	// map reference - this makes it easier for concurrency purposes (can't
+	// lock in a select condition)
+	c.kLocksV2Mutex.Lock()
+	lock := c.kLocksV2[key]
+	c.kLocksV2Mutex.Unlock()
+
+	c.runRefreshLoop(lock, id, key, func() error {
+		return c.tryRefresh(id, key)
+	})
+}
+
+// renewDeadline returns how long a v2 lock may go without being refreshed before its Lease ends, two
+// thirds of the lock TTL.
+func (c *configMap) renewDeadline() time.Duration {
+	return c.lockK8sLockTTL - c.lockK8sLockTTL/3
+}
+
+// runRefreshLoop keeps the given v2 lock refreshed by calling tryRefresh until the lock is unlocked or lost.
+// It is shared by the exclusive and the shared locks.
+func (c *configMap) runRefreshLoop(lock *k8sLock, id, key string, tryRefresh func() error) {
 	fn := "refreshLock"
 	refresh := time.NewTicker(c.lockRefreshDuration)
 	defer refresh.Stop()
@@ -362,13 +477,6 @@ func (c *configMap) refreshLock(id, key string) {
 		startTime      time.Time
 	)
 
-	// get a reference to the lock object so we don't have to hold open a
-	// map reference - this makes it easier for concurrency purposes (can't
-	// lock in a select condition)
-	c.kLocksV2Mutex.Lock()
-	lock := c.kLocksV2[key]
-	c.kLocksV2Mutex.Unlock()
-
 	if lock == nil {
 		// could happen if the lock was unlocked before the goroutine started
 		configMapLog(fn, c.name, "", key, nil).Warnf("Lock not found for key %s; refresh goroutine exiting", key)
@@ -384,15 +492,49 @@ func (c *configMap) refreshLock(id, key string) {
 	lock.Unlock()
 
 	startTime = time.Now()
+
+	// A lease ends once its lock could not be refreshed for the renew deadline, before the lock
+	// expires and can be taken by another owner. The deadline fires even while a refresh is stuck
+	// on an unreachable apiserver.
+	renewDeadline := c.renewDeadline()
+	var deadline *time.Timer
+	expired := make(chan struct{})
+	if lock.cancel != nil {
+		deadline = time.AfterFunc(renewDeadline, func() {
+			lock.cancelLease(fmt.Errorf("%w: not refreshed for %v", ErrConfigMapLockLost, renewDeadline))
+			close(expired)
+		})
+		defer deadline.Stop()
+	}
+
 	for {
 		select {
 		case <-refresh.C:
 			lock.Lock()
 			isConflictErrCount := 0
 			for !lock.unlocked {
+				select {
+				case <-expired:
+					configMapLog(fn, c.name, id, key, nil).Errorf(
+						"Lock not refreshed within %v; giving up the lease", renewDeadline)
+					lock.unlocked = true
+					lock.Unlock()
+					return
+				default:
+				}
+				if lock.cancel != nil && c.lockHoldTimedOut(c.defaultLockHoldTimeout, startTime) {
+					// Leases don't panic on a hold timeout. Stop refreshing so that the lock expires and
+					// let the holder abort its work through the lease context.
+					configMapLog(fn, c.name, id, key, nil).Errorf(
+						"Lock hold timeout (%v) triggered; giving up the lease", c.defaultLockHoldTimeout)
+					lock.unlocked = true
+					lock.cancelLease(ErrConfigMapLockHoldTimeout)
+					lock.Unlock()
+					return
+				}
 				c.checkLockTimeout(c.defaultLockHoldTimeout, startTime, id)
 				currentRefresh = time.Now()
-				if _, err := c.tryLock(id, key, true); err != nil {
+				if err := tryRefresh(); err != nil {
 					if k8s_errors.IsConflict(err) {
 						isConflictErrCount++
 						if isConflictErrCount%10 == 0 {
@@ -413,9 +555,14 @@ func (c *configMap) refreshLock(id, key string) {
 					if errors.Is(err, ErrConfigMapLockLost) {
 						// there is no coming back from this
 						lock.unlocked = true
+						lock.cancelLease(ErrConfigMapLockLost)
 						lock.Unlock()
 						return
 					}
+				} else if deadline != nil && deadline.Stop() {
+					// the new expiration was computed before the refresh; the deadline is only
+					// re-armed if it has not fired yet
+					deadline.Reset(renewDeadline - time.Since(currentRefresh))
 				}
 				thresh := c.lockRefreshDuration * 3 / 2
 				if !prevRefresh.IsZero() && prevRefresh.Add(thresh).Before(currentRefresh) {
@@ -434,8 +581,12 @@ func (c *configMap) refreshLock(id, key string) {
 
 }
 
+func (c *configMap) lockHoldTimedOut(holdTimeout time.Duration, startTime time.Time) bool {
+	return holdTimeout > 0 && time.Since(startTime) > holdTimeout
+}
+
 func (c *configMap) checkLockTimeout(holdTimeout time.Duration, startTime time.Time, id string) {
-	if holdTimeout > 0 && time.Since(startTime) > holdTimeout {
+	if c.lockHoldTimedOut(holdTimeout, startTime) {
 		panicMsg := fmt.Sprintf("Lock hold timeout (%v) triggered for K8s configmap lock key %s", holdTimeout, id)
 		if fatalCb != nil {
 			fatalCb(panicMsg)
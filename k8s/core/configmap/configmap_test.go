@@ -5,12 +5,14 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	coreops "github.com/portworx/sched-ops/k8s/core"
 	"github.com/portworx/sched-ops/k8s/testutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestGetConfigMap(t *testing.T) {
@@ -42,6 +44,60 @@ func TestDeleteConfigMap(t *testing.T) {
 	require.NoError(t, err, "Unexpected error in delete")
 }
 
+func TestNewWithOptions(t *testing.T) {
+	// lock domains with injected clients must not depend on the singleton
+	coreops.SetInstance(nil)
+	ops1 := coreops.New(fake.NewSimpleClientset())
+	ops2 := coreops.New(fake.NewSimpleClientset())
+
+	name := "px-configmaps-options-test"
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: "owner-pod", UID: "owner-uid"}
+	cm1, err := NewWithOptions(name, map[string]string{"key1": "val1"},
+		WithNamespace("tenant1"),
+		WithCoreOps(ops1),
+		WithOwnerReferences(owner),
+		WithLabels(map[string]string{"app": "locks", configMapUserLabelKey: "ignored"}),
+		WithAnnotations(map[string]string{"note": "test"}),
+		WithLockAttempts(1),
+		WithLockRefreshDuration(testLockRefreshDuration),
+		WithLockTTL(testLockTTL),
+	)
+	require.NoError(t, err, "Unexpected error in creating configmap")
+	cm2, err := NewWithOptions(name, nil, WithNamespace("tenant1"), WithCoreOps(ops2), WithLockAttempts(1))
+	require.NoError(t, err, "Unexpected error in creating configmap")
+
+	rawCM, err := ops1.GetConfigMap(name, "tenant1")
+	require.NoError(t, err)
+	require.Equal(t, "val1", rawCM.Data["key1"])
+	require.Equal(t, "locks", rawCM.Labels["app"])
+	require.Equal(t, TruncateLabel(name), rawCM.Labels[configMapUserLabelKey])
+	require.Equal(t, "test", rawCM.Annotations["note"])
+	require.Equal(t, []metav1.OwnerReference{owner}, rawCM.OwnerReferences)
+	_, err = ops1.GetConfigMap(name, k8sSystemNamespace)
+	require.Error(t, err, "Expected no configmap in kube-system")
+
+	// the two configmaps are independent lock domains
+	require.NoError(t, cm1.LockWithKey("options-id1", "options-key1"))
+	require.NoError(t, cm2.LockWithKey("options-id2", "options-key1"))
+	require.NoError(t, cm1.PatchKeyLocked(false, "options-id1", "options-key1", "val"))
+	require.NoError(t, cm1.UnlockWithKey("options-key1"))
+	require.NoError(t, cm2.UnlockWithKey("options-key1"))
+
+	cm3, err := NewWithOptions(name+"-lease", nil, WithNamespace("tenant1"), WithCoreOps(ops1),
+		WithOwnerReferences(owner), WithLockBackend(LockBackendLease))
+	require.NoError(t, err, "Unexpected error in creating configmap")
+	require.NoError(t, cm3.LockWithKey("options-id1", "options-key1"))
+	lease, err := ops1.GetLease(cm3.(*configMap).leaseName("options-key1"), "tenant1")
+	require.NoError(t, err)
+	require.Equal(t, []metav1.OwnerReference{owner}, lease.OwnerReferences)
+	require.Equal(t, "options-key1", lease.Annotations[leaseLockKeyAnnotation])
+	require.NoError(t, cm3.UnlockWithKey("options-key1"))
+
+	_, err = NewWithOptions(name+"-refresh", nil, WithCoreOps(ops1),
+		WithLockRefreshDuration(2*time.Second), WithLockTTL(3*time.Second))
+	require.Error(t, err, "Expected a refresh duration above two thirds of the TTL to be rejected")
+}
+
 func TestIncrementGeneration(t *testing.T) {
 	setUpConfigMapTestCluster(t)
 
@@ -1,10 +1,15 @@
 package configmap
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/portworx/sched-ops/k8s/core"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -22,7 +27,7 @@ const (
 	v2DefaultK8sLockTTL = 60 * time.Second
 	// v2DefaultK8sLockRefreshDuration is the time duration after which a lock is refreshed
 	v2DefaultK8sLockRefreshDuration = 20 * time.Second
-	// k8sSystemNamespace is the namespace in which we create the ConfigMap
+	// k8sSystemNamespace is the default namespace in which we create the ConfigMap
 	k8sSystemNamespace = "kube-system"
 
 	// ***********************
@@ -41,10 +46,19 @@ const (
 	// objects.
 	pxLockKey = "px-lock"
 
+	// pxLockQueueKey is the key which stores the ordered queue of waiters for the v2 locks when fair
+	// locking is enabled. The data in this key is stored in JSON as an array of lockWaiter objects.
+	pxLockQueueKey = "px-lock-queue"
+
 	// pxGenerationKey stores the generation of the configmap data. The value is incremented every time
 	// the configmap data is updated via PatchKeyLocked or DeleteKeyLocked. This is used for diagnostics purposes only.
 	pxGenerationKey = "px-generation"
 
+	// lockEventReportingController is the reporting controller of the events recorded for the locks
+	lockEventReportingController = "configmap-lock"
+	// lockForceUnlockReason is the reason of the event recorded by ForceUnlock
+	lockForceUnlockReason = "LockForceUnlocked"
+
 	lockSleepDuration                     = 1 * time.Second
 	lockRandomSleepDurationMaxMillisecond = 1000
 	configMapUserLabelKey                 = "user"
@@ -56,10 +70,123 @@ var (
 	ErrConfigMapLocked = errors.New("ConfigMap is locked")
 	// ErrConfigMapLockLost is returned when the ConfigMap lock expired and was taken away
 	ErrConfigMapLockLost = errors.New("ConfigMap lock was lost")
-	fatalCb              FatalCb
-	configMapNameRegex   = regexp.MustCompile("[^a-zA-Z0-9]+")
+	// ErrConfigMapLockReleased is the cause of a Lease context cancelled because the lock was unlocked
+	ErrConfigMapLockReleased = errors.New("ConfigMap lock was released")
+	// ErrConfigMapLockHoldTimeout is the cause of a Lease context cancelled because the lock was held
+	// for longer than the lock hold timeout
+	ErrConfigMapLockHoldTimeout = errors.New("ConfigMap lock hold timeout exceeded")
+	fatalCb                     FatalCb
+	configMapNameRegex          = regexp.MustCompile("[^a-zA-Z0-9]+")
 )
 
+// LockBackend selects where the v2 locks taken with LockWithKey and LockWithKeyCtx are stored.
+// The data of the configMap is always stored in the ConfigMap object.
+//
+// To move existing v2 lock users from LockBackendConfigMap to LockBackendLease without a flag day,
+// first roll out LockBackendMigrating to every user of the configMap. Once no user is left on
+// LockBackendConfigMap, roll out LockBackendLease.
+type LockBackend string
+
+const (
+	// LockBackendConfigMap stores all the v2 locks in the px-lock key of the ConfigMap. This is the default.
+	LockBackendConfigMap LockBackend = "configmap"
+	// LockBackendLease stores every v2 lock in its own coordination.k8s.io/v1 Lease object, which is
+	// deleted when the lock is released. Shared locks and fair locking are not supported with this backend.
+	LockBackendLease LockBackend = "lease"
+	// LockBackendMigrating takes every v2 lock both in the ConfigMap and in a Lease object. It
+	// excludes users of either of the other backends and is used while migrating between them.
+	// Shared locks are not supported with this backend.
+	LockBackendMigrating LockBackend = "migrating"
+)
+
+// Option customizes the configMap returned by New and NewWithOptions.
+type Option func(*configMap)
+
+// WithLockBackend selects the backend used for the v2 locks.
+func WithLockBackend(backend LockBackend) Option {
+	return func(c *configMap) {
+		c.lockBackend = backend
+	}
+}
+
+// WithNamespace sets the namespace of the ConfigMap and of the Lease objects. It defaults to kube-system.
+func WithNamespace(namespace string) Option {
+	return func(c *configMap) {
+		c.namespace = namespace
+	}
+}
+
+// WithCoreOps sets the client used to access k8s. It defaults to core.Instance().
+func WithCoreOps(ops core.Ops) Option {
+	return func(c *configMap) {
+		c.coreOps = ops
+	}
+}
+
+// WithOwnerReferences sets the owner references of the ConfigMap and of the Lease objects so that they
+// are garbage collected with their owners. They are only set when the objects are created.
+func WithOwnerReferences(refs ...meta_v1.OwnerReference) Option {
+	return func(c *configMap) {
+		c.ownerReferences = append(c.ownerReferences, refs...)
+	}
+}
+
+// WithLabels adds labels to the ConfigMap and to the Lease objects when they are created. The "user"
+// label is reserved.
+func WithLabels(labels map[string]string) Option {
+	return func(c *configMap) {
+		if c.labels == nil {
+			c.labels = map[string]string{}
+		}
+		for k, v := range labels {
+			c.labels[k] = v
+		}
+	}
+}
+
+// WithAnnotations adds annotations to the ConfigMap and to the Lease objects when they are created.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(c *configMap) {
+		if c.annotations == nil {
+			c.annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			c.annotations[k] = v
+		}
+	}
+}
+
+// WithLockHoldTimeout sets the default time within which a v2 lock should be released. It defaults to
+// DefaultK8sLockTimeout.
+func WithLockHoldTimeout(timeout time.Duration) Option {
+	return func(c *configMap) {
+		c.defaultLockHoldTimeout = timeout
+	}
+}
+
+// WithLockAttempts sets the number of times to try taking a lock before failing. It defaults to
+// DefaultK8sLockAttempts.
+func WithLockAttempts(attempts uint) Option {
+	return func(c *configMap) {
+		c.lockAttempts = attempts
+	}
+}
+
+// WithLockRefreshDuration sets the time after which a v2 lock is refreshed. It must be less than two
+// thirds of the lock TTL.
+func WithLockRefreshDuration(refresh time.Duration) Option {
+	return func(c *configMap) {
+		c.lockRefreshDuration = refresh
+	}
+}
+
+// WithLockTTL sets the time after which a v2 lock expires unless it is refreshed.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(c *configMap) {
+		c.lockK8sLockTTL = ttl
+	}
+}
+
 // FatalCb is a callback function which will be executed if the Lock
 // routine encounters a panic situation
 type FatalCb func(format string, args ...interface{})
@@ -69,14 +196,23 @@ type CheckLockCb func(data map[string]string) bool
 
 type configMap struct {
 	name                   string
+	namespace              string
+	coreOps                core.Ops
+	labels                 map[string]string
+	annotations            map[string]string
+	ownerReferences        []meta_v1.OwnerReference
 	kLockV1                k8sLock
 	kLocksV2Mutex          sync.Mutex
 	kLocksV2               map[string]*k8sLock
+	kRLocksMutex           sync.Mutex
+	kRLocks                map[readerLockID]*k8sLock
 	lockHoldTimeoutV1      time.Duration
 	defaultLockHoldTimeout time.Duration
 	lockAttempts           uint
 	lockRefreshDuration    time.Duration
 	lockK8sLockTTL         time.Duration
+	fairLocking            atomic.Bool
+	lockBackend            LockBackend
 }
 
 type k8sLock struct {
@@ -85,9 +221,18 @@ type k8sLock struct {
 	unlocked   bool
 	refreshing bool
 	id         string
+	// cancel cancels the context of the Lease for locks taken with LockWithKeyCtx
+	cancel context.CancelCauseFunc
 	sync.Mutex
 }
 
+// cancelLease cancels the context of the Lease holding this lock, if any, with the given cause.
+func (l *k8sLock) cancelLease(cause error) {
+	if l.cancel != nil {
+		l.cancel(cause)
+	}
+}
+
 // ConfigMap is an interface that provides a set of APIs over a single
 // k8s configmap object. The data in the configMap is managed as a map of string
 // to string.
@@ -109,12 +254,46 @@ type ConfigMap interface {
 	// LockWithKey locks a configMap where owner is the identification
 	// of the holder of the lock and key is the specific lock to take.  Lock is non-reentrant.
 	LockWithKey(owner, key string) error
+	// LockWithKeyCtx is similar to LockWithKey but stops waiting for the lock when ctx is done
+	// and returns a Lease that reports when the lock is lost. The lock is released once ctx is done.
+	LockWithKeyCtx(ctx context.Context, owner, key string) (Lease, error)
+	// SetFairLocking enables or disables fair v2 locking. In fair mode, LockWithKey and LockWithKeyCtx
+	// grant the lock for a key in the order in which the owners started waiting for it. The waiters
+	// are kept in a queue in the configMap; waiters that stop polling for the lock are dropped from
+	// the queue after the lock TTL. All the owners locking the same keys should enable fair locking,
+	// otherwise owners not using it can still take the lock ahead of the queue.
+	// Fair locking is ignored with the LockBackendLease lock backend.
+	SetFairLocking(enabled bool)
+	// RLockWithKey takes a shared lock on the given key in the configMap where owner is the
+	// identification of the holder of the lock. Many owners can hold a shared lock on the same key
+	// at the same time, but not while an exclusive lock taken with LockWithKey is held on it.
+	// A shared lock does not allow PatchKeyLocked or DeleteKeyLocked. RLock is non-reentrant.
+	//
+	// New shared locks are refused while a writer waits in the fair lock queue of the key. Writers
+	// only queue up with fair locking enabled (see SetFairLocking); without it, a steady flow of
+	// readers can starve LockWithKey on the same key.
+	//
+	// Shared locks are only supported with the LockBackendConfigMap lock backend; they are rejected
+	// with LockBackendLease and LockBackendMigrating. Versions of this package older than shared
+	// locks read the shared entries of the lock data as exclusive locks and drop their Shared flag
+	// when they write the data back, so every user of the configMap must be upgraded before any of
+	// them takes a shared lock.
+	RLockWithKey(owner, key string) error
 	// Unlock unlocks the configMap.
 	Unlock() error
 	// UnlockWithKey unlocks the given key in the configMap.
 	UnlockWithKey(key string) error
+	// RUnlockWithKey releases the shared lock held by owner on the given key in the configMap.
+	RUnlockWithKey(owner, key string) error
 	// IsKeyLocked returns if the given key is locked, and if so, by which owner.
 	IsKeyLocked(key, requester string) (bool, string, error)
+	// ListLocks returns the unexpired v2 locks, exclusive and shared, held in the configMap ordered
+	// by key and owner. It is meant for diagnostics; the locks may change as soon as it returns.
+	ListLocks() ([]LockInfo, error)
+	// ForceUnlock releases the exclusive v2 lock on the given key regardless of its owner and records
+	// a Kubernetes event with the given reason on the ConfigMap. The owner of the lock loses it as if
+	// it had expired. It is a no-op if the key is not locked.
+	ForceUnlock(key, reason string) error
 
 	// PatchKeyLocked updates the specified key in the configMap. It verifies that
 	// the lock is still held by the specified owner. Lock needs to be held by the lockOwner
@@ -126,15 +305,59 @@ type ConfigMap interface {
 
 	// Get returns the contents of the configMap
 	Get() (map[string]string, error)
-	// Delete deletes the configMap
+	// Delete deletes the configMap, and the Lease objects of its locks with the LockBackendLease and
+	// LockBackendMigrating lock backends
 	Delete() error
 }
 
+// Lease is a v2 lock taken with LockWithKeyCtx. Unlike LockWithKey, losing the lock or exceeding the
+// lock hold timeout does not invoke the fatal callback. Instead the lease context is cancelled so that
+// the work done under the lock can abort cooperatively.
+type Lease interface {
+	// Key returns the locked key.
+	Key() string
+	// Owner returns the owner of the lock.
+	Owner() string
+	// Done returns a channel that is closed when the lock is lost or released. It is the same
+	// channel as Context().Done(). The lock is considered lost once it could not be refreshed for
+	// two thirds of the lock TTL, e.g. while the apiserver is unreachable, so that the holder stops
+	// before the lock expires and another owner can take it.
+	Done() <-chan struct{}
+	// Context returns a context that is cancelled when the lock is lost or released. The lock is
+	// released when the context given to LockWithKeyCtx is done.
+	Context() context.Context
+	// Err returns nil while the lock is held. Otherwise it returns why the lease ended:
+	// ErrConfigMapLockLost, ErrConfigMapLockHoldTimeout, ErrConfigMapLockReleased or the
+	// error of the context given to LockWithKeyCtx.
+	Err() error
+	// Unlock releases the lock. It is a no-op if the lock was already lost or released.
+	Unlock() error
+}
+
+// LockInfo describes a v2 lock returned by ListLocks.
+type LockInfo struct {
+	// Key is the locked key
+	Key string
+	// Owner is the owner of the lock
+	Owner string
+	// Shared is true for shared locks taken with RLockWithKey
+	Shared bool
+	// Expiration is the time at which the lock expires unless it is refreshed by its owner
+	Expiration time.Time
+	// Remaining is the time left before Expiration when the locks were listed
+	Remaining time.Duration
+	// Generation is the generation of the configMap data when the locks were listed
+	Generation uint64
+}
+
 // lockData structs are serialized into JSON and stored as a list inside a ConfigMap.
 // Each lockData struct contains the owner (usually which node took the lock), key
 // (which specific lock it's taking), and an expiration time after which the lock is invalid.
+// Shared locks taken with RLockWithKey are stored in the same list with Shared set; a key
+// can have many shared entries, one per owner.
 type lockData struct {
 	Owner      string    `json:"owner"`
 	Key        string    `json:"key"`
 	Expiration time.Time `json:"expiration"`
+	Shared     bool      `json:"shared,omitempty"`
 }
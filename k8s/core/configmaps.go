@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -21,6 +22,8 @@ type ConfigMapOps interface {
 	UpdateConfigMap(configMap *corev1.ConfigMap) (*corev1.ConfigMap, error)
 	// WatchConfigMap sets up a watcher that listens for changes on the config map
 	WatchConfigMap(configMap *corev1.ConfigMap, fn WatchFunc) error
+	// WatchConfigMapWithContext watches the config map until ctx is done, the watcher is stopped or fn returns an error
+	WatchConfigMapWithContext(ctx context.Context, configMap *corev1.ConfigMap, fn schedwatch.EventFunc) (*schedwatch.Watcher, error)
 	//ListConfigMap returns the list of ConfigMaps
 	ListConfigMap(namespace string, filterOptions metav1.ListOptions) (*corev1.ConfigMapList, error)
 }
@@ -79,24 +82,27 @@ func (c *Client) UpdateConfigMap(configMap *corev1.ConfigMap) (*corev1.ConfigMap
 
 // WatchConfigMap sets up a watcher that listens for changes on the config map
 func (c *Client) WatchConfigMap(configMap *corev1.ConfigMap, fn WatchFunc) error {
+	return schedwatch.InBackground(c.WatchConfigMapWithContext(context.Background(), configMap, schedwatch.ObjectFunc(fn)))
+}
+
+// WatchConfigMapWithContext watches the config map like WatchConfigMap, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchConfigMapWithContext(ctx context.Context, configMap *corev1.ConfigMap, fn schedwatch.EventFunc) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
 	listOptions := metav1.ListOptions{
 		FieldSelector: fields.OneTermEqualSelector("metadata.name", configMap.Name).String(),
-		Watch:         true,
 	}
 
-	watchInterface, err := c.kubernetes.CoreV1().ConfigMaps(configMap.Namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*corev1.ConfigMapList](c.kubernetes.CoreV1().ConfigMaps(configMap.Namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for config maps")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, configMap, "", fn, listOptions)
-	return nil
+	return w, nil
 }
 
 // ListConfigMap returns the list of ConfigMaps
@@ -1,21 +1,16 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
-	"time"
 
 	"github.com/portworx/sched-ops/k8s/common"
-	"github.com/portworx/sched-ops/task"
-	"github.com/sirupsen/logrus"
-	certv1 "k8s.io/api/certificates/v1"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/version"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -29,7 +24,10 @@ const (
 	controlDashPlaneLabelKey           = "node-role.kubernetes.io/control-plane"
 	pvcStorageProvisionerKeyDeprecated = "volume.beta.kubernetes.io/storage-provisioner"
 	pvcStorageProvisionerKey           = "volume.kubernetes.io/storage-provisioner"
+	labelOSBeta                        = "beta.kubernetes.io/os"
 	labelUpdateMaxRetries              = 5
+	// drainWaitConcurrency is the max number of pods waited on in parallel while draining a node
+	drainWaitConcurrency = 10
 )
 
 var (
@@ -45,6 +43,7 @@ type Ops interface {
 	EndpointsOps
 	EventOps
 	RecorderOps
+	LeaseOps
 	NamespaceOps
 	NodeOps
 	PersistentVolumeClaimOps
@@ -58,6 +57,8 @@ type Ops interface {
 
 	// SetConfig sets the config and resets the client
 	SetConfig(config *rest.Config)
+	// EnableCache serves the hot read paths of the client from shared informers until ctx is done
+	EnableCache(ctx context.Context, opts *CacheOptions) error
 	// GetVersion gets the version from the kubernetes cluster
 	GetVersion() (*version.Info, error)
 	// ResourceExists returns true if given resource type exists in kubernetes API server
@@ -94,6 +95,7 @@ func NewForConfig(c *rest.Config) (*Client, error) {
 	}
 
 	return &Client{
+		config:     c,
 		kubernetes: kubernetes,
 	}, nil
 }
@@ -127,12 +129,17 @@ type Client struct {
 	// (https://pkg.go.dev/k8s.io/api/events/v1)
 	eventRecordersNew   map[string]events.EventRecorder
 	eventBroadcasterNew events.EventBroadcaster
+
+	// cache serves the hot read paths once enabled by EnableCache
+	cacheMu sync.Mutex
+	cache   *clientCache
 }
 
 // SetConfig sets the config and resets the client.
 func (c *Client) SetConfig(cfg *rest.Config) {
 	c.config = cfg
 	c.kubernetes = nil
+	c.disableCache()
 }
 
 // GetVersion returns server version
@@ -231,58 +238,6 @@ func (c *Client) loadClient() error {
 }
 
 // WatchFunc is a callback provided to the Watch functions
-// which is invoked when the given object is changed.
+// which is invoked when the given object is changed. Returning
+// schedwatch.ErrStopWatch stops the watch, other errors are ignored.
 type WatchFunc func(object runtime.Object) error
-
-// handleWatch is internal function that handles the watch.  On channel shutdown (ie. stop watch),
-// it'll attempt to reestablish its watch function.
-//
-// NOTE: When a new kind of watcher is added, handleWatch() needs to be updated to re-establish the watch
-// for the new object type.
-func (c *Client) handleWatch(
-	watchInterface watch.Interface,
-	object runtime.Object,
-	namespace string,
-	fn WatchFunc,
-	listOptions metav1.ListOptions,
-) {
-	defer watchInterface.Stop()
-	for {
-		select {
-		case event, more := <-watchInterface.ResultChan():
-			if !more {
-				logrus.Debug("Kubernetes watch closed (attempting to re-establish)")
-
-				t := func() (interface{}, bool, error) {
-					var err error
-					if node, ok := object.(*corev1.Node); ok {
-						err = c.WatchNode(node, fn)
-					} else if cm, ok := object.(*corev1.ConfigMap); ok {
-						err = c.WatchConfigMap(cm, fn)
-					} else if _, ok := object.(*corev1.Pod); ok {
-						err = c.WatchPods(namespace, fn, listOptions)
-					} else if _, ok := object.(*corev1.Event); ok {
-						err = c.WatchEvents(namespace, fn, listOptions)
-					} else if sc, ok := object.(*corev1.Secret); ok {
-						err = c.WatchSecret(sc, fn)
-					} else if csr, ok := object.(*certv1.CertificateSigningRequest); ok {
-						err = c.WatchCertificateSigningRequests(csr, fn)
-					} else {
-						return "", false, fmt.Errorf("unsupported object: %v given to handle watch", object)
-					}
-
-					return "", true, err
-				}
-
-				if _, err := task.DoRetryWithTimeout(t, 10*time.Minute, 10*time.Second); err != nil {
-					logrus.WithError(err).Error("Could not re-establish the watch")
-				} else {
-					logrus.Debug("watch re-established")
-				}
-				return
-			}
-
-			fn(event.Object)
-		}
-	}
-}
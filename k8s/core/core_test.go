@@ -1,9 +1,18 @@
 package core
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestInstance(t *testing.T) {
@@ -11,3 +20,71 @@ func TestInstance(t *testing.T) {
 
 	require.NotNil(t, instance, "instance should be initialized")
 }
+
+func TestWatchReestablished(t *testing.T) {
+	limitRange := &corev1.LimitRange{ObjectMeta: metav1.ObjectMeta{Name: "lr1", Namespace: "ns1", ResourceVersion: "1"}}
+	clientset := fake.NewSimpleClientset(limitRange)
+	watchers := make(chan *watch.FakeWatcher, 2)
+	clientset.PrependWatchReactor("limitranges", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+	names := make(chan string, 10)
+	client := New(clientset)
+
+	require.NoError(t, client.WatchLimitRange(limitRange, func(object runtime.Object) error {
+		names <- object.(*corev1.LimitRange).Name
+		return nil
+	}))
+	require.Equal(t, "lr1", <-names, "Expected the existing limit range to be reported")
+
+	// closing the watch re-establishes it
+	(<-watchers).Stop()
+	select {
+	case w := <-watchers:
+		w.Modify(limitRange)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watch to be re-established")
+	}
+	require.Equal(t, "lr1", <-names)
+}
+
+func TestWatchWithContext(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newDrainPod("pod1"))
+	client := New(clientset)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan watch.EventType, 10)
+	w, err := client.WatchPodsWithContext(ctx, "ns1", func(event watch.Event) error {
+		events <- event.Type
+		if event.Type == watch.Deleted {
+			return schedwatch.ErrStopWatch
+		}
+		return nil
+	}, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, watch.Added, <-events)
+
+	pod := newDrainPod("pod1")
+	pod.Labels = map[string]string{"app": "test"}
+	_, err = clientset.CoreV1().Pods("ns1").Update(context.TODO(), pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, watch.Modified, <-events)
+	require.NoError(t, clientset.CoreV1().Pods("ns1").Delete(context.TODO(), "pod1", metav1.DeleteOptions{}))
+	require.Equal(t, watch.Deleted, <-events)
+	<-w.Done()
+	require.NoError(t, w.Err(), "Expected ErrStopWatch to stop the watch without error")
+
+	// cancelling ctx stops the watch
+	w, err = client.WatchEventsWithContext(ctx, "ns1", func(event watch.Event) error { return nil }, metav1.ListOptions{})
+	require.NoError(t, err)
+	cancel()
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the watch to stop once ctx is cancelled")
+	}
+	require.NoError(t, w.Err())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	certv1 "k8s.io/api/certificates/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -17,6 +18,7 @@ type CertificateOps interface {
 	GetCertificateSigningRequest(name string) (*certv1.CertificateSigningRequest, error)
 	DeleteCertificateSigningRequests(name string) error
 	WatchCertificateSigningRequests(csr *certv1.CertificateSigningRequest, fn WatchFunc) error
+	WatchCertificateSigningRequestsWithContext(ctx context.Context, csr *certv1.CertificateSigningRequest, fn schedwatch.EventFunc) (*schedwatch.Watcher, error)
 	CertificateSigningRequestsUpdateApproval(name string, csr *certv1.CertificateSigningRequest) (*certv1.CertificateSigningRequest, error)
 }
 
@@ -139,23 +141,22 @@ func (c *Client) DeleteCertificateSigningRequests(name string) error {
 // WatchCertificateSigningRequests reports changes on the requested CSR
 // - CAUTION: Must populate at least csr.Name
 func (c *Client) WatchCertificateSigningRequests(csr *certv1.CertificateSigningRequest, fn WatchFunc) error {
+	return schedwatch.InBackground(c.WatchCertificateSigningRequestsWithContext(context.Background(), csr, schedwatch.ObjectFunc(fn)))
+}
+
+// WatchCertificateSigningRequestsWithContext watches the CSR like WatchCertificateSigningRequests, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchCertificateSigningRequestsWithContext(ctx context.Context, csr *certv1.CertificateSigningRequest, fn schedwatch.EventFunc) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
 	listOptions := metav1.ListOptions{
 		FieldSelector: fields.OneTermEqualSelector("metadata.name", csr.Name).String(),
-		Watch:         true,
-	}
-
-	watchInterface, err := c.kubernetes.CertificatesV1().CertificateSigningRequests().Watch(context.TODO(), listOptions)
-	if err != nil {
-		return err
 	}
 
-	// fire off watch function
-	go c.handleWatch(watchInterface, csr, "", fn, listOptions)
-	return nil
+	source := schedwatch.ForClient[*certv1.CertificateSigningRequestList](c.kubernetes.CertificatesV1().CertificateSigningRequests())
+	return schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 }
 
 // CertificateSigningRequestsUpdateApproval used to approve or decline the CSR
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/portworx/sched-ops/task"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultDrainRetryInterval is the time between the eviction attempts of a pod blocked by a
+	// PodDisruptionBudget, and between the checks for the deletion of an evicted pod
+	defaultDrainRetryInterval = 5 * time.Second
+	// drainCordonTimeout is the max time to cordon and uncordon the node being drained
+	drainCordonTimeout = time.Minute
+	// mirrorPodAnnotation is set by the kubelet on the API server copy of static pods
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+)
+
+// DrainPodStatus is the outcome of draining a single pod
+type DrainPodStatus string
+
+const (
+	// DrainPodEvicted is the status of a pod which was evicted and deleted
+	DrainPodEvicted DrainPodStatus = "Evicted"
+	// DrainPodSkipped is the status of a pod which is left on the node, like DaemonSet and mirror pods
+	DrainPodSkipped DrainPodStatus = "Skipped"
+	// DrainPodWouldEvict is the status of a pod which would be evicted in dry-run mode
+	DrainPodWouldEvict DrainPodStatus = "WouldEvict"
+	// DrainPodFailed is the status of a pod which could not be evicted
+	DrainPodFailed DrainPodStatus = "Failed"
+)
+
+// DrainOptions are the options of DrainNode
+type DrainOptions struct {
+	// IgnoreDaemonSets leaves the pods managed by a DaemonSet on the node. Otherwise the drain fails if
+	// there are any, as they would be recreated on the node right away.
+	IgnoreDaemonSets bool
+	// DeleteEmptyDirData evicts the pods using emptyDir volumes, whose data is lost. Otherwise the
+	// drain fails if there are any.
+	DeleteEmptyDirData bool
+	// GracePeriodSeconds overrides the termination grace period of the evicted pods if set
+	GracePeriodSeconds *int64
+	// Concurrency is the max number of pods evicted in parallel. It defaults to 10.
+	Concurrency int
+	// Timeout is the max time to evict all the pods and wait for their deletion. Zero means no limit.
+	Timeout time.Duration
+	// RetryInterval is the time between the eviction attempts of a pod blocked by a PodDisruptionBudget.
+	// It defaults to 5 seconds.
+	RetryInterval time.Duration
+	// DryRun reports the pods which would be evicted without cordoning the node or evicting them
+	DryRun bool
+}
+
+// DrainPodResult is the outcome of draining a single pod
+type DrainPodResult struct {
+	// Namespace is the namespace of the pod
+	Namespace string
+	// Name is the name of the pod
+	Name string
+	// Status is the outcome of draining the pod
+	Status DrainPodStatus
+	// Reason explains why the pod was skipped or failed
+	Reason string
+	// Attempts is the number of eviction attempts, more than one if a PodDisruptionBudget blocked it
+	Attempts int
+	// Duration is the time it took to evict the pod and wait for its deletion
+	Duration time.Duration
+}
+
+// DrainResult is the report of DrainNode with the outcome of every pod on the node
+type DrainResult struct {
+	// NodeName is the name of the drained node
+	NodeName string
+	// Pods are the outcomes of the pods which were on the node
+	Pods []DrainPodResult
+}
+
+// DrainNode cordons the given node and evicts its pods using the Eviction API, so that the
+// PodDisruptionBudgets are honored; evictions blocked by a budget are retried until opts.Timeout.
+// Mirror pods are always skipped. If any pod fails to be evicted, the node is uncordoned and the
+// returned error lists the failed pods. The result reports the outcome of every pod in every case.
+func (c *Client) DrainNode(ctx context.Context, nodeName string, opts *DrainOptions) (*DrainResult, error) {
+	if opts == nil {
+		opts = &DrainOptions{}
+	}
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	pods, err := c.kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DrainResult{NodeName: nodeName}
+	var toEvict []int
+	var blocked int
+	for _, pod := range pods.Items {
+		podResult := filterDrainPod(pod, opts)
+		switch podResult.Status {
+		case DrainPodFailed:
+			blocked++
+		case DrainPodWouldEvict:
+			toEvict = append(toEvict, len(result.Pods))
+		}
+		result.Pods = append(result.Pods, podResult)
+	}
+	if blocked > 0 {
+		return result, fmt.Errorf("cannot drain node %s: %d pods cannot be evicted", nodeName, blocked)
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := c.CordonNode(nodeName, drainCordonTimeout, drainRetryInterval(opts)); err != nil {
+		return result, err
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = drainWaitConcurrency
+	}
+	_, err = task.RunParallel(ctx, toEvict, concurrency, func(ctx context.Context, i int) (interface{}, error) {
+		start := time.Now()
+		attempts, err := c.evictPod(ctx, pods.Items[i], opts)
+		result.Pods[i].Attempts = attempts
+		result.Pods[i].Duration = time.Since(start)
+		if err != nil {
+			result.Pods[i].Status = DrainPodFailed
+			result.Pods[i].Reason = err.Error()
+			return nil, fmt.Errorf("failed to evict pod %s/%s: %w", result.Pods[i].Namespace, result.Pods[i].Name, err)
+		}
+		result.Pods[i].Status = DrainPodEvicted
+		return nil, nil
+	})
+	if err != nil {
+		// pods which were not started before ctx was done are not reported by evictPod
+		for _, i := range toEvict {
+			if result.Pods[i].Status == DrainPodWouldEvict {
+				result.Pods[i].Status = DrainPodFailed
+				result.Pods[i].Reason = ctx.Err().Error()
+			}
+		}
+		if e := c.UnCordonNode(nodeName, drainCordonTimeout, drainRetryInterval(opts)); e != nil { // rollback cordon
+			logrus.WithError(e).Errorf("failed to uncordon node: %s", nodeName)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// filterDrainPod returns whether the pod can be evicted with the given options. The pods to evict have
+// the DrainPodWouldEvict status.
+func filterDrainPod(pod corev1.Pod, opts *DrainOptions) DrainPodResult {
+	podResult := DrainPodResult{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Status:    DrainPodWouldEvict,
+	}
+
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		podResult.Status = DrainPodSkipped
+		podResult.Reason = "mirror pod"
+		return podResult
+	}
+	if controller := metav1.GetControllerOf(&pod); controller != nil && controller.Kind == "DaemonSet" {
+		if opts.IgnoreDaemonSets {
+			podResult.Status = DrainPodSkipped
+			podResult.Reason = "managed by DaemonSet " + controller.Name
+		} else {
+			podResult.Status = DrainPodFailed
+			podResult.Reason = "managed by DaemonSet " + controller.Name + ", use IgnoreDaemonSets to skip it"
+		}
+		return podResult
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		// completed pods are evicted regardless of their volumes as they have no data left to lose
+		return podResult
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil && !opts.DeleteEmptyDirData {
+			podResult.Status = DrainPodFailed
+			podResult.Reason = "uses emptyDir volume " + volume.Name + ", use DeleteEmptyDirData to evict it"
+			return podResult
+		}
+	}
+	return podResult
+}
+
+// evictPod evicts the pod and waits for its deletion. The eviction is retried while a
+// PodDisruptionBudget blocks it. It returns the number of eviction attempts.
+func (c *Client) evictPod(ctx context.Context, pod corev1.Pod, opts *DrainOptions) (int, error) {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{
+			GracePeriodSeconds: opts.GracePeriodSeconds,
+		},
+	}
+
+	// the attempts run in their own goroutine and may still be running when ctx is done
+	var attempts atomic.Int32
+	evict := func() (interface{}, bool, error) {
+		attempts.Add(1)
+		err := c.kubernetes.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		if err == nil || apierrors.IsNotFound(err) {
+			return nil, false, nil
+		}
+		// the API server returns 429 when the eviction would violate a PodDisruptionBudget
+		return nil, apierrors.IsTooManyRequests(err), err
+	}
+	backoff := task.ConstantBackoff(drainRetryInterval(opts))
+	operation := task.WithOperation("core.DrainNode")
+	if _, err := task.DoRetryWithContext(ctx, evict, backoff, operation); err != nil {
+		return int(attempts.Load()), err
+	}
+
+	waitForDeletion := func() (interface{}, bool, error) {
+		p, err := c.kubernetes.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && p.UID != pod.UID) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		return nil, true, fmt.Errorf("pod %s/%s (%s) still present in the system", pod.Namespace, pod.Name, pod.UID)
+	}
+	_, err := task.DoRetryWithContext(ctx, waitForDeletion, backoff, operation)
+	return int(attempts.Load()), err
+}
+
+func drainRetryInterval(opts *DrainOptions) time.Duration {
+	if opts.RetryInterval > 0 {
+		return opts.RetryInterval
+	}
+	return defaultDrainRetryInterval
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newDrainPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1", UID: types.UID("uid-" + name)},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+// newDrainClient returns a client whose evictions delete the pod, except for the pods in pdbBlocked
+// which are refused with 429 the given number of times and the pods in failing which always fail.
+func newDrainClient(pdbBlocked map[string]int, failing map[string]bool, pods ...runtime.Object) (*Client, *fake.Clientset) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	clientset := fake.NewSimpleClientset(append(pods, node)...)
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if failing[eviction.Name] {
+			return true, nil, apierrors.NewInternalError(errors.New("eviction failed"))
+		}
+		if pdbBlocked[eviction.Name] > 0 {
+			pdbBlocked[eviction.Name]--
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		podsResource := corev1.SchemeGroupVersion.WithResource("pods")
+		return true, nil, clientset.Tracker().Delete(podsResource, eviction.Namespace, eviction.Name)
+	})
+	return New(clientset), clientset
+}
+
+func TestDrainNode(t *testing.T) {
+	daemonSetPod := newDrainPod("ds")
+	daemonSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds1", Controller: &[]bool{true}[0]}}
+	mirrorPod := newDrainPod("mirror")
+	mirrorPod.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+	client, clientset := newDrainClient(map[string]int{"blocked": 2}, nil,
+		newDrainPod("app"), newDrainPod("blocked"), daemonSetPod, mirrorPod)
+	opts := &DrainOptions{IgnoreDaemonSets: true, RetryInterval: 10 * time.Millisecond, Timeout: 5 * time.Second}
+
+	opts.DryRun = true
+	result, err := client.DrainNode(context.Background(), "node1", opts)
+	require.NoError(t, err)
+	statuses := map[string]DrainPodStatus{}
+	for _, pod := range result.Pods {
+		statuses[pod.Name] = pod.Status
+	}
+	require.Equal(t, map[string]DrainPodStatus{
+		"app":     DrainPodWouldEvict,
+		"blocked": DrainPodWouldEvict,
+		"ds":      DrainPodSkipped,
+		"mirror":  DrainPodSkipped,
+	}, statuses)
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, node.Spec.Unschedulable, "Dry-run should not cordon the node")
+
+	opts.DryRun = false
+	result, err = client.DrainNode(context.Background(), "node1", opts)
+	require.NoError(t, err)
+	for _, pod := range result.Pods {
+		switch pod.Name {
+		case "app":
+			require.Equal(t, DrainPodEvicted, pod.Status)
+			require.Equal(t, 1, pod.Attempts)
+		case "blocked":
+			require.Equal(t, DrainPodEvicted, pod.Status)
+			require.Equal(t, 3, pod.Attempts, "Expected the eviction to be retried while blocked by the PDB")
+		default:
+			require.Equal(t, DrainPodSkipped, pod.Status)
+		}
+	}
+	node, err = clientset.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, node.Spec.Unschedulable)
+	pods, err := clientset.CoreV1().Pods("ns1").List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, pods.Items, 2)
+}
+
+func TestDrainNodeBlockedPods(t *testing.T) {
+	daemonSetPod := newDrainPod("ds")
+	daemonSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds1", Controller: &[]bool{true}[0]}}
+	emptyDirPod := newDrainPod("scratch")
+	emptyDirPod.Spec.Volumes = []corev1.Volume{{
+		Name:         "data",
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}}
+	client, clientset := newDrainClient(nil, nil, daemonSetPod, emptyDirPod)
+
+	result, err := client.DrainNode(context.Background(), "node1", &DrainOptions{})
+	require.EqualError(t, err, "cannot drain node node1: 2 pods cannot be evicted")
+	require.Len(t, result.Pods, 2)
+	for _, pod := range result.Pods {
+		require.Equal(t, DrainPodFailed, pod.Status)
+		require.NotEmpty(t, pod.Reason)
+	}
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, node.Spec.Unschedulable)
+
+	result, err = client.DrainNode(context.Background(), "node1", &DrainOptions{
+		IgnoreDaemonSets:   true,
+		DeleteEmptyDirData: true,
+		RetryInterval:      10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Pods, 2)
+}
+
+func TestDrainNodeRollback(t *testing.T) {
+	client, clientset := newDrainClient(nil, map[string]bool{"broken": true}, newDrainPod("app"), newDrainPod("broken"))
+
+	result, err := client.DrainNode(context.Background(), "node1", &DrainOptions{RetryInterval: 10 * time.Millisecond})
+	require.Error(t, err)
+	statuses := map[string]DrainPodStatus{}
+	for _, pod := range result.Pods {
+		statuses[pod.Name] = pod.Status
+	}
+	require.Equal(t, map[string]DrainPodStatus{"app": DrainPodEvicted, "broken": DrainPodFailed}, statuses)
+
+	node, err := clientset.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, node.Spec.Unschedulable, "Expected the node to be uncordoned after the failed drain")
+}
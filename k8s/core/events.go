@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +24,8 @@ type EventOps interface {
 	ListEvents(namespace string, opts metav1.ListOptions) (*corev1.EventList, error)
 	// WatchEvents sets up a watcher that listens for events in given namespace or all namespaces if the namespace is empty
 	WatchEvents(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchEventsWithContext watches the events in given namespace until ctx is done, the watcher is stopped or fn returns an error
+	WatchEventsWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // CreateEvent puts an event into k8s etcd
@@ -51,26 +54,23 @@ func (c *Client) ListEvents(namespace string, opts metav1.ListOptions) (*corev1.
 
 // WatchEvents sets up a watcher that listens for events in given namespace or all namespaces if the namespace is empty
 func (c *Client) WatchEvents(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchEventsWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchEventsWithContext watches the events like WatchEvents, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchEventsWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.kubernetes.CoreV1().Events(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*corev1.EventList](c.kubernetes.CoreV1().Events(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for events")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(
-		watchInterface,
-		&corev1.Event{},
-		namespace,
-		fn,
-		listOptions)
-
-	return nil
+	return w, nil
 }
 
 // RecorderOps is an interface to record k8s events
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LeaseOps is an interface to perform k8s coordination Lease operations
+type LeaseOps interface {
+	// GetLease gets the lease object for the given name and namespace
+	GetLease(name, namespace string) (*coordinationv1.Lease, error)
+	// CreateLease creates the given lease object
+	CreateLease(lease *coordinationv1.Lease) (*coordinationv1.Lease, error)
+	// UpdateLease updates the given lease object
+	UpdateLease(lease *coordinationv1.Lease) (*coordinationv1.Lease, error)
+	// DeleteLease deletes the given lease
+	DeleteLease(name, namespace string) error
+	// DeleteLeaseIfUnchanged deletes the given lease object unless it was updated or re-created since it was read
+	DeleteLeaseIfUnchanged(lease *coordinationv1.Lease) error
+	// ListLeases returns the list of leases in the given namespace
+	ListLeases(namespace string, listOptions metav1.ListOptions) (*coordinationv1.LeaseList, error)
+}
+
+// GetLease gets the lease object for the given name and namespace
+func (c *Client) GetLease(name, namespace string) (*coordinationv1.Lease, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// CreateLease creates the given lease object
+func (c *Client) CreateLease(lease *coordinationv1.Lease) (*coordinationv1.Lease, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	ns := lease.Namespace
+	if len(ns) == 0 {
+		ns = corev1.NamespaceDefault
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(ns).Create(context.TODO(), lease, metav1.CreateOptions{})
+}
+
+// UpdateLease updates the given lease object
+func (c *Client) UpdateLease(lease *coordinationv1.Lease) (*coordinationv1.Lease, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	ns := lease.Namespace
+	if len(ns) == 0 {
+		ns = corev1.NamespaceDefault
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(ns).Update(context.TODO(), lease, metav1.UpdateOptions{})
+}
+
+// DeleteLease deletes the given lease
+func (c *Client) DeleteLease(name, namespace string) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+// DeleteLeaseIfUnchanged deletes the given lease object unless it was updated or re-created since it was read
+func (c *Client) DeleteLeaseIfUnchanged(lease *coordinationv1.Lease) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	ns := lease.Namespace
+	if len(ns) == 0 {
+		ns = corev1.NamespaceDefault
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(ns).Delete(context.TODO(), lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{
+			UID:             &lease.UID,
+			ResourceVersion: &lease.ResourceVersion,
+		},
+	})
+}
+
+// ListLeases returns the list of leases in the given namespace
+func (c *Client) ListLeases(namespace string, listOptions metav1.ListOptions) (*coordinationv1.LeaseList, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	return c.kubernetes.CoordinationV1().Leases(namespace).List(context.TODO(), listOptions)
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -22,6 +23,8 @@ type LimitRangeOps interface {
 	DeleteLimitRange(name, namespace string) error
 	// WatchLimitRange changes and callback fn
 	WatchLimitRange(*corev1.LimitRange, WatchFunc) error
+	// WatchLimitRangeWithContext watches the limit range until ctx is done, the watcher is stopped or fn returns an error
+	WatchLimitRangeWithContext(context.Context, *corev1.LimitRange, schedwatch.EventFunc) (*schedwatch.Watcher, error)
 }
 
 // GetLimitRange gets the limitranges object given its name and namespace
@@ -73,21 +76,20 @@ func (c *Client) DeleteLimitRange(name, namespace string) error {
 
 // WatchLimitRange changes and callback fn
 func (c *Client) WatchLimitRange(limitrange *corev1.LimitRange, fn WatchFunc) error {
+	return schedwatch.InBackground(c.WatchLimitRangeWithContext(context.Background(), limitrange, schedwatch.ObjectFunc(fn)))
+}
+
+// WatchLimitRangeWithContext watches the limit range like WatchLimitRange, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchLimitRangeWithContext(ctx context.Context, limitrange *corev1.LimitRange, fn schedwatch.EventFunc) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
 	listOptions := metav1.ListOptions{
 		FieldSelector: fields.OneTermEqualSelector("metadata.name", limitrange.Name).String(),
-		Watch:         true,
-	}
-
-	watchInterface, err := c.kubernetes.CoreV1().LimitRanges(limitrange.Namespace).Watch(context.TODO(), listOptions)
-	if err != nil {
-		return err
 	}
 
-	// fire off watch function
-	go c.handleWatch(watchInterface, limitrange, "", fn, listOptions)
-	return nil
+	source := schedwatch.ForClient[*corev1.LimitRangeList](c.kubernetes.CoreV1().LimitRanges(limitrange.Namespace))
+	return schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 }
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"reflect"
+
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// NodeHealth is a summary of the conditions and scheduling state of a node
+type NodeHealth struct {
+	// Name is the name of the node
+	Name string
+	// Ready is true if the Ready condition of the node is True
+	Ready bool
+	// MemoryPressure is true if the MemoryPressure condition of the node is True
+	MemoryPressure bool
+	// DiskPressure is true if the DiskPressure condition of the node is True
+	DiskPressure bool
+	// PIDPressure is true if the PIDPressure condition of the node is True
+	PIDPressure bool
+	// NetworkUnavailable is true if the NetworkUnavailable condition of the node is True
+	NetworkUnavailable bool
+	// Unschedulable is true if the node is cordoned
+	Unschedulable bool
+	// Taints are the taints of the node
+	Taints []corev1.Taint
+	// KubeletVersion is the version of the kubelet running on the node
+	KubeletVersion string
+}
+
+// Healthy returns true if the node is ready and has no pressure or network condition
+func (h *NodeHealth) Healthy() bool {
+	return h.Ready && !h.MemoryPressure && !h.DiskPressure && !h.PIDPressure && !h.NetworkUnavailable
+}
+
+// NewNodeHealth returns the health summary of the given node
+func NewNodeHealth(node *corev1.Node) *NodeHealth {
+	return &NodeHealth{
+		Name:               node.Name,
+		Ready:              nodeConditionStatus(node, corev1.NodeReady) == corev1.ConditionTrue,
+		MemoryPressure:     nodeConditionStatus(node, corev1.NodeMemoryPressure) == corev1.ConditionTrue,
+		DiskPressure:       nodeConditionStatus(node, corev1.NodeDiskPressure) == corev1.ConditionTrue,
+		PIDPressure:        nodeConditionStatus(node, corev1.NodePIDPressure) == corev1.ConditionTrue,
+		NetworkUnavailable: nodeConditionStatus(node, corev1.NodeNetworkUnavailable) == corev1.ConditionTrue,
+		Unschedulable:      node.Spec.Unschedulable,
+		Taints:             node.Spec.Taints,
+		KubeletVersion:     node.Status.NodeInfo.KubeletVersion,
+	}
+}
+
+// NodeHealthFunc is called by WatchNodeHealth when the health of a node changes. old is nil for the
+// nodes seen for the first time and new is nil for the deleted nodes. It is never called concurrently.
+type NodeHealthFunc func(old, new *NodeHealth)
+
+// GetNodeHealth returns the health summary of the given node
+func (c *Client) GetNodeHealth(name string) (*NodeHealth, error) {
+	node, err := c.GetNodeByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewNodeHealth(node), nil
+}
+
+// WaitForNodeCondition waits until the given condition of the node has the given status or ctx is
+// done. A condition the node does not report has the Unknown status.
+func (c *Client) WaitForNodeCondition(ctx context.Context, nodeName string, conditionType corev1.NodeConditionType, status corev1.ConditionStatus) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	node, err := c.kubernetes.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if nodeConditionStatus(node, conditionType) == status {
+		return nil
+	}
+
+	fn := func(event watch.Event) error {
+		if event.Type != watch.Added && event.Type != watch.Modified {
+			return nil
+		}
+		if node, ok := event.Object.(*corev1.Node); ok && node.Name == nodeName && nodeConditionStatus(node, conditionType) == status {
+			return schedwatch.ErrStopWatch
+		}
+		return nil
+	}
+	w, err := schedwatch.Start(ctx, schedwatch.ForClient[*corev1.NodeList](c.kubernetes.CoreV1().Nodes()), fn, &schedwatch.Options{
+		ListOptions: metav1.ListOptions{
+			FieldSelector:   fields.OneTermEqualSelector("metadata.name", nodeName).String(),
+			ResourceVersion: node.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	<-w.Done()
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// WatchNodeHealth calls fn whenever the health summary of a node changes until ctx is done. fn is first
+// called for every existing node. Changes missed while the watch is re-established, including the
+// deleted nodes, are reported once it is. It returns the ctx error once ctx is done, or the error of the watch if it fails.
+func (c *Client) WatchNodeHealth(ctx context.Context, fn NodeHealthFunc) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	known := map[string]*NodeHealth{}
+	onEvent := func(event watch.Event) error {
+		node, ok := event.Object.(*corev1.Node)
+		if !ok {
+			return nil
+		}
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			updateNodeHealth(known, node.Name, NewNodeHealth(node), fn)
+		case watch.Deleted:
+			updateNodeHealth(known, node.Name, nil, fn)
+		}
+		return nil
+	}
+	w, err := schedwatch.Start(ctx, schedwatch.ForClient[*corev1.NodeList](c.kubernetes.CoreV1().Nodes()), onEvent, nil)
+	if err != nil {
+		return err
+	}
+
+	<-w.Done()
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// updateNodeHealth records the health of the node and calls fn if it changed. A nil health means the
+// node was deleted.
+func updateNodeHealth(known map[string]*NodeHealth, name string, health *NodeHealth, fn NodeHealthFunc) {
+	old := known[name]
+	if reflect.DeepEqual(old, health) {
+		return
+	}
+	if health == nil {
+		delete(known, name)
+	} else {
+		known[name] = health
+	}
+	fn(old, health)
+}
+
+// nodeConditionStatus returns the status of the given condition of the node, or Unknown if the node
+// does not report it.
+func nodeConditionStatus(node *corev1.Node, conditionType corev1.NodeConditionType) corev1.ConditionStatus {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
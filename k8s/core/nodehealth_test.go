@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newHealthNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready},
+				{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+			},
+			NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.25.1"},
+		},
+	}
+}
+
+func setNodeCondition(node *corev1.Node, conditionType corev1.NodeConditionType, status corev1.ConditionStatus) {
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == conditionType {
+			node.Status.Conditions[i].Status = status
+			return
+		}
+	}
+	node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{Type: conditionType, Status: status})
+}
+
+func TestGetNodeHealth(t *testing.T) {
+	node := newHealthNode("node1", corev1.ConditionTrue)
+	node.Spec.Unschedulable = true
+	node.Spec.Taints = []corev1.Taint{{Key: "k", Effect: corev1.TaintEffectNoSchedule}}
+	setNodeCondition(node, corev1.NodeDiskPressure, corev1.ConditionTrue)
+	client := New(fake.NewSimpleClientset(node))
+
+	health, err := client.GetNodeHealth("node1")
+	require.NoError(t, err)
+	require.Equal(t, &NodeHealth{
+		Name:           "node1",
+		Ready:          true,
+		DiskPressure:   true,
+		Unschedulable:  true,
+		Taints:         node.Spec.Taints,
+		KubeletVersion: "v1.25.1",
+	}, health)
+	require.False(t, health.Healthy())
+}
+
+func TestWaitForNodeCondition(t *testing.T) {
+	node := newHealthNode("node1", corev1.ConditionFalse)
+	client := New(fake.NewSimpleClientset(node, newHealthNode("node2", corev1.ConditionFalse)))
+
+	require.NoError(t, client.WaitForNodeCondition(context.Background(), "node1", corev1.NodeReady, corev1.ConditionFalse))
+	require.NoError(t, client.WaitForNodeCondition(context.Background(), "node1", corev1.NodePIDPressure, corev1.ConditionUnknown))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WaitForNodeCondition(context.Background(), "node1", corev1.NodeReady, corev1.ConditionTrue)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	// a different node becoming ready does not count
+	node2 := newHealthNode("node2", corev1.ConditionTrue)
+	_, err := client.kubernetes.CoreV1().Nodes().Update(context.TODO(), node2, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	select {
+	case err := <-errCh:
+		t.Fatalf("WaitForNodeCondition returned early: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	setNodeCondition(node, corev1.NodeReady, corev1.ConditionTrue)
+	_, err = client.kubernetes.CoreV1().Nodes().Update(context.TODO(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	select {
+	case err := <-errCh:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the node condition")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = client.WaitForNodeCondition(ctx, "node1", corev1.NodeReady, corev1.ConditionFalse)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWatchNodeHealth(t *testing.T) {
+	type transition struct {
+		old, new *NodeHealth
+	}
+	node := newHealthNode("node1", corev1.ConditionTrue)
+	client := New(fake.NewSimpleClientset(node))
+
+	transitions := make(chan transition, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WatchNodeHealth(ctx, func(old, new *NodeHealth) {
+			transitions <- transition{old: old, new: new}
+		})
+	}()
+	next := func() transition {
+		select {
+		case tr := <-transitions:
+			return tr
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a node health transition")
+		}
+		return transition{}
+	}
+
+	tr := next()
+	require.Nil(t, tr.old)
+	require.True(t, tr.new.Healthy())
+
+	// heartbeats do not change the health
+	node.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+	_, err := client.kubernetes.CoreV1().Nodes().Update(context.TODO(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	setNodeCondition(node, corev1.NodeMemoryPressure, corev1.ConditionTrue)
+	_, err = client.kubernetes.CoreV1().Nodes().Update(context.TODO(), node, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	tr = next()
+	require.True(t, tr.old.Healthy())
+	require.True(t, tr.new.MemoryPressure)
+	require.False(t, tr.new.Healthy())
+
+	require.NoError(t, client.kubernetes.CoreV1().Nodes().Delete(context.TODO(), "node1", metav1.DeleteOptions{}))
+	tr = next()
+	require.True(t, tr.old.MemoryPressure)
+	require.Nil(t, tr.new)
+
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+	require.Empty(t, transitions)
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 	"time"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 )
 
 // NodeOps is an interface to perform k8s node operations
@@ -21,6 +24,8 @@ type NodeOps interface {
 	UpdateNode(n *corev1.Node) (*corev1.Node, error)
 	// GetNodes talks to the k8s api server and gets the nodes in the cluster
 	GetNodes() (*corev1.NodeList, error)
+	// ListNodes returns the nodes matching the label and field selectors, a nil selector matches all the nodes
+	ListNodes(labelSelector labels.Selector, fieldSelector fields.Selector) (*corev1.NodeList, error)
 	// GetNodeByName returns the k8s node given it's name
 	GetNodeByName(string) (*corev1.Node, error)
 	// SearchNodeByAddresses searches corresponding k8s node match any of the given address
@@ -29,6 +34,12 @@ type NodeOps interface {
 	FindMyNode() (*corev1.Node, error)
 	// IsNodeReady checks if node with given name is ready. Returns nil is ready.
 	IsNodeReady(string) error
+	// GetNodeHealth returns the summary of the conditions and scheduling state of the given node
+	GetNodeHealth(name string) (*NodeHealth, error)
+	// WaitForNodeCondition waits until the given condition of the node has the given status or ctx is done
+	WaitForNodeCondition(ctx context.Context, nodeName string, conditionType corev1.NodeConditionType, status corev1.ConditionStatus) error
+	// WatchNodeHealth calls fn whenever the health summary of a node changes until ctx is done
+	WatchNodeHealth(ctx context.Context, fn NodeHealthFunc) error
 	// IsNodeMaster returns true if given node is a kubernetes master node
 	IsNodeMaster(corev1.Node) bool
 	// GetLabelsOnNode gets all the labels on the given node
@@ -37,15 +48,27 @@ type NodeOps interface {
 	AddLabelOnNode(string, string, string) error
 	// RemoveLabelOnNode removes the label with key on given node
 	RemoveLabelOnNode(string, string) error
+	// GetTaints returns the taints of the given node
+	GetTaints(nodeName string) ([]corev1.Taint, error)
+	// AddTaintOnNode adds the taint on the given node, replacing a taint with the same key and effect
+	AddTaintOnNode(nodeName string, taint corev1.Taint) error
+	// RemoveTaintOnNode removes the taint with the given key and effect, or all the taints with the key if the effect is empty
+	RemoveTaintOnNode(nodeName, key string, effect corev1.TaintEffect) error
+	// WaitForPodsToTolerateTaint waits until all the pods running on the given node tolerate the taint
+	WaitForPodsToTolerateTaint(ctx context.Context, nodeName string, taint corev1.Taint) error
 	// WatchNode sets up a watcher that listens for the changes on input node.Incase of input node as nil, It will watch on all the nodes
 	WatchNode(node *corev1.Node, fn WatchFunc) error
+	// WatchNodeWithContext watches the node, or all the nodes if it is nil, until ctx is done, the watcher is stopped or fn returns an error
+	WatchNodeWithContext(ctx context.Context, node *corev1.Node, fn schedwatch.EventFunc) (*schedwatch.Watcher, error)
 	// CordonNode cordons the given node
 	CordonNode(nodeName string, timeout, retryInterval time.Duration) error
 	// UnCordonNode uncordons the given node
 	UnCordonNode(nodeName string, timeout, retryInterval time.Duration) error
 	// DrainPodsFromNode drains given pods from given node. If timeout is set to
-	// a non-zero value, it waits for timeout duration for each pod to get deleted
+	// a non-zero value, it waits for timeout duration for the pods to get deleted
 	DrainPodsFromNode(nodeName string, pods []corev1.Pod, timeout, retryInterval time.Duration) error
+	// DrainNode cordons the given node and evicts its pods honoring the PodDisruptionBudgets
+	DrainNode(ctx context.Context, nodeName string, opts *DrainOptions) (*DrainResult, error)
 	// DeleteNode deletes the given node
 	DeleteNode(name string) error
 	// GetWindowsNodes talks to the k8s api server and returns the Windows Nodes in the cluster
@@ -288,25 +311,24 @@ func (c *Client) RemoveLabelOnNode(name, key string) error {
 
 // WatchNode sets up a watcher that listens for the changes on input node and will watch all the nodes when input node is nil.
 func (c *Client) WatchNode(node *corev1.Node, watchNodeFn WatchFunc) error {
+	return schedwatch.InBackground(c.WatchNodeWithContext(context.Background(), node, schedwatch.ObjectFunc(watchNodeFn)))
+}
+
+// WatchNodeWithContext watches the node like WatchNode, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchNodeWithContext(ctx context.Context, node *corev1.Node, fn schedwatch.EventFunc) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
-	}
-	listOptions := metav1.ListOptions{
-		Watch: true,
+		return nil, err
 	}
+	listOptions := metav1.ListOptions{}
 	if node != nil {
 		listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", node.Name).String()
 	} else {
 		fmt.Printf("Watching all nodes")
 	}
-	watchInterface, err := c.kubernetes.CoreV1().Nodes().Watch(context.TODO(), listOptions)
-	if err != nil {
-		return err
-	}
 
-	// fire off watch function
-	go c.handleWatch(watchInterface, node, "", watchNodeFn, listOptions)
-	return nil
+	source := schedwatch.ForClient[*corev1.NodeList](c.kubernetes.CoreV1().Nodes())
+	return schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 }
 
 // CordonNode cordons the given node
@@ -332,7 +354,7 @@ func (c *Client) CordonNode(nodeName string, timeout, retryInterval time.Duratio
 
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("core.CordonNode")); err != nil {
 		return err
 	}
 
@@ -362,7 +384,7 @@ func (c *Client) UnCordonNode(nodeName string, timeout, retryInterval time.Durat
 
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("core.UnCordonNode")); err != nil {
 		return err
 	}
 
@@ -370,7 +392,8 @@ func (c *Client) UnCordonNode(nodeName string, timeout, retryInterval time.Durat
 }
 
 // DrainPodsFromNode drains given pods from given node. If timeout is set to
-// a non-zero value, it waits for timeout duration for each pod to get deleted
+// a non-zero value, it waits for timeout duration for the pods to get deleted.
+// The pods are waited on in parallel.
 func (c *Client) DrainPodsFromNode(nodeName string, pods []corev1.Pod, timeout time.Duration, retryInterval time.Duration) error {
 	err := c.CordonNode(nodeName, timeout, retryInterval)
 	if err != nil {
@@ -387,80 +410,126 @@ func (c *Client) DrainPodsFromNode(nodeName string, pods []corev1.Pod, timeout t
 	}
 
 	if timeout > 0 {
-		for _, p := range pods {
-			err = c.WaitForPodDeletion(p.UID, p.Namespace, timeout)
-			if err != nil {
-				return err
-			}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_, err = task.RunParallel(ctx, pods, drainWaitConcurrency, func(ctx context.Context, p corev1.Pod) (interface{}, error) {
+			return nil, c.waitForPodDeletion(ctx, p.UID, p.Namespace)
+		})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (c *Client) getTaggedNodes(partialLabelName, partialLabelValue string, readyOnlyNodes bool) (*corev1.NodeList, error) {
+// ListNodes returns the nodes matching the label and field selectors. The selectors are applied by
+// the API server; a nil selector matches all the nodes.
+func (c *Client) ListNodes(labelSelector labels.Selector, fieldSelector fields.Selector) (*corev1.NodeList, error) {
 	if err := c.initClient(); err != nil {
 		return nil, err
 	}
 
-	allNodes, err := c.kubernetes.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	listOptions := metav1.ListOptions{}
+	if labelSelector != nil {
+		listOptions.LabelSelector = labelSelector.String()
+	}
+	if fieldSelector != nil {
+		listOptions.FieldSelector = fieldSelector.String()
+	}
+	return c.kubernetes.CoreV1().Nodes().List(context.TODO(), listOptions)
+}
+
+// getNodesWithOS returns the nodes running the given operating system, and only the ready ones if
+// readyOnlyNodes is set. The nodes are selected by the API server on their os label, or on the
+// deprecated beta os label for the older nodes which only have the latter. The label value must
+// match exactly; the kubelet sets it to the lower case name of the operating system.
+func (c *Client) getNodesWithOS(os string, readyOnlyNodes bool) (*corev1.NodeList, error) {
+	nodes, err := c.ListNodes(labels.SelectorFromSet(labels.Set{corev1.LabelOSStable: os}), nil)
 	if err != nil {
 		return nil, err
 	}
-	var retNodes corev1.NodeList
-	for _, n := range allNodes.Items {
-		if readyOnlyNodes {
-			readyErr := c.checkReadyStatus(&n, n.Name)
-			if readyErr != nil {
-				continue
-			}
-		}
-		if partialLabelName != "" && partialLabelValue != "" {
-			for k, v := range n.GetLabels() {
-				if strings.Contains(k, partialLabelName) {
-					if strings.EqualFold(v, partialLabelValue) {
-						retNodes.Items = append(retNodes.Items, n)
-					}
-					break // break from label, os label found
-				}
-			}
-		} else {
-			retNodes.Items = append(retNodes.Items, n)
+
+	betaOS, err := labels.NewRequirement(labelOSBeta, selection.Equals, []string{os})
+	if err != nil {
+		return nil, err
+	}
+	noOS, err := labels.NewRequirement(corev1.LabelOSStable, selection.DoesNotExist, nil)
+	if err != nil {
+		return nil, err
+	}
+	betaNodes, err := c.ListNodes(labels.NewSelector().Add(*betaOS, *noOS), nil)
+	if err != nil {
+		return nil, err
+	}
+	nodes.Items = append(nodes.Items, betaNodes.Items...)
+
+	if readyOnlyNodes {
+		nodes.Items = c.filterReadyNodes(nodes.Items)
+	}
+	return nodes, nil
+}
+
+func (c *Client) filterReadyNodes(nodes []corev1.Node) []corev1.Node {
+	readyNodes := make([]corev1.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if c.checkReadyStatus(&n, n.Name) == nil {
+			readyNodes = append(readyNodes, n)
 		}
 	}
-	return &retNodes, nil
+	return readyNodes
 }
 
 // GetLinuxNodes talks to the k8s api server and returns the linux nodes in the cluster
 func (c *Client) GetLinuxNodes() (*corev1.NodeList, error) {
-	return c.getTaggedNodes("kubernetes.io/os", "linux", false)
+	return c.getNodesWithOS("linux", false)
 }
 
 // GetWindowsNodes talks to the k8s api server to get all nodes and filter on labels to get Windows nodes
 func (c *Client) GetWindowsNodes() (*corev1.NodeList, error) {
-	return c.getTaggedNodes("kubernetes.io/os", "windows", false)
+	return c.getNodesWithOS("windows", false)
 }
 
 // GetReadyLinuxNodes talks to the k8s api server to get all nodes and filters linux nodes that are Ready.
 func (c *Client) GetReadyLinuxNodes() (*corev1.NodeList, error) {
-	return c.getTaggedNodes("kubernetes.io/os", "linux", true)
+	return c.getNodesWithOS("linux", true)
 }
 
 // GetReadyWindowsNodes talks to the k8s api server to get all nodes and filter on labels to get Windows nodes that are Ready.
 func (c *Client) GetReadyWindowsNodes() (*corev1.NodeList, error) {
-	return c.getTaggedNodes("kubernetes.io/os", "windows", true)
+	return c.getNodesWithOS("windows", true)
 }
 
-// GetNodesUsingVolume Returns the list of nodes using a Pv.
+// GetNodesUsingVolume Returns the list of nodes using a Pv. The pods using the Pv are listed once and
+// indexed by node, instead of listing the pods of every node. The list is empty if the Pv does not exist.
 func (c *Client) GetNodesUsingVolume(pvName string, readyNodesOnly bool) (*corev1.NodeList, error) {
-	allNodes, err := c.getTaggedNodes("", "", readyNodesOnly)
+	pods, err := c.GetPodsUsingPV(pvName)
+	if apierrors.IsNotFound(err) {
+		return &corev1.NodeList{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	usedByNode := make(map[string]bool, len(pods))
+	for _, p := range pods {
+		if p.Spec.NodeName != "" {
+			usedByNode[p.Spec.NodeName] = true
+		}
+	}
+
 	var pvNodes corev1.NodeList
+	if len(usedByNode) == 0 {
+		return &pvNodes, nil
+	}
+	allNodes, err := c.ListNodes(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if readyNodesOnly {
+		allNodes.Items = c.filterReadyNodes(allNodes.Items)
+	}
 	for _, n := range allNodes.Items {
-		pods, err := c.GetPodsUsingPVByNodeName(pvName, n.Name)
-		if err == nil && (len(pods) > 0) {
+		if usedByNode[n.Name] {
 			pvNodes.Items = append(pvNodes.Items, n)
 		}
 	}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func nodeNames(nodes *corev1.NodeList) []string {
+	names := make([]string, 0, len(nodes.Items))
+	for _, n := range nodes.Items {
+		names = append(names, n.Name)
+	}
+	return names
+}
+
+func TestListNodes(t *testing.T) {
+	linux := newHealthNode("linux", corev1.ConditionTrue)
+	linux.Labels = map[string]string{corev1.LabelOSStable: "linux"}
+	notReady := newHealthNode("not-ready", corev1.ConditionFalse)
+	notReady.Labels = map[string]string{corev1.LabelOSStable: "linux"}
+	windows := newHealthNode("windows", corev1.ConditionTrue)
+	windows.Labels = map[string]string{corev1.LabelOSStable: "windows"}
+	// a label merely containing the os label key is not matched
+	other := newHealthNode("other", corev1.ConditionTrue)
+	other.Labels = map[string]string{"example.com/kubernetes.io/os": "linux"}
+	// older nodes may only have the beta label
+	beta := newHealthNode("beta", corev1.ConditionTrue)
+	beta.Labels = map[string]string{"beta.kubernetes.io/os": "linux"}
+	// the os label takes precedence over the beta label
+	both := newHealthNode("both", corev1.ConditionTrue)
+	both.Labels = map[string]string{corev1.LabelOSStable: "windows", "beta.kubernetes.io/os": "linux"}
+	clientset := fake.NewSimpleClientset(linux, notReady, windows, other, beta, both)
+	client := New(clientset)
+
+	nodes, err := client.ListNodes(labels.SelectorFromSet(labels.Set{corev1.LabelOSStable: "windows"}), nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"windows", "both"}, nodeNames(nodes))
+	nodes, err = client.ListNodes(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, nodes.Items, 6)
+
+	clientset.ClearActions()
+	nodes, err = client.GetLinuxNodes()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"linux", "not-ready", "beta"}, nodeNames(nodes))
+	for _, action := range clientset.Actions() {
+		list, ok := action.(k8stesting.ListActionImpl)
+		require.True(t, ok, "Expected only node lists, got %v", action)
+		require.False(t, list.ListRestrictions.Labels.Empty(), "Expected the nodes to be selected by the API server")
+	}
+	nodes, err = client.GetReadyLinuxNodes()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"linux", "beta"}, nodeNames(nodes))
+	nodes, err = client.GetReadyWindowsNodes()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"windows", "both"}, nodeNames(nodes))
+}
+
+func TestGetNodesUsingVolume(t *testing.T) {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv1"},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "pvc1", Namespace: "ns1"},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+	newPod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+			Spec: corev1.PodSpec{
+				NodeName: node,
+				Volumes: []corev1.Volume{{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc1"},
+					},
+				}},
+				Containers: []corev1.Container{{Name: "c1", VolumeMounts: []corev1.VolumeMount{{Name: "data"}}}},
+			},
+		}
+	}
+	clientset := fake.NewSimpleClientset(pv,
+		newHealthNode("node1", corev1.ConditionTrue),
+		newHealthNode("node2", corev1.ConditionFalse),
+		newHealthNode("node3", corev1.ConditionTrue),
+		newPod("pod1", "node1"), newPod("pod2", "node2"))
+	podLists := 0
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		podLists++
+		return false, nil, nil
+	})
+	client := New(clientset)
+
+	nodes, err := client.GetNodesUsingVolume("pv1", false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"node1", "node2"}, nodeNames(nodes))
+	require.Equal(t, 1, podLists, "Expected a single pod list")
+
+	nodes, err = client.GetNodesUsingVolume("pv1", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"node1"}, nodeNames(nodes))
+
+	nodes, err = client.GetNodesUsingVolume("missing", false)
+	require.NoError(t, err)
+	require.Empty(t, nodes.Items)
+}
@@ -26,7 +26,8 @@ type PersistentVolumeClaimOps interface {
 	ValidatePersistentVolumeClaim(vv *corev1.PersistentVolumeClaim, timeout, retryInterval time.Duration) error
 	// ValidatePersistentVolumeClaimSize validates the given pvc size
 	ValidatePersistentVolumeClaimSize(vv *corev1.PersistentVolumeClaim, expectedPVCSize int64, timeout, retryInterval time.Duration) error
-	// GetPersistentVolumeClaim returns the PVC for given name and namespace
+	// GetPersistentVolumeClaim returns the PVC for given name and namespace. It is served from the cache
+	// once enabled by EnableCache, and can then return NotFound right after the PVC is created.
 	GetPersistentVolumeClaim(pvcName string, namespace string) (*corev1.PersistentVolumeClaim, error)
 	// GetPersistentVolumeClaims returns all PVCs in given namespace and that match the optional labelSelector
 	// Deprecated: Use GetPersistentVolumeClaimsUsingLabelSelector instead.
@@ -118,7 +119,7 @@ func (c *Client) ValidatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("core.ValidatePersistentVolumeClaim")); err != nil {
 		return err
 	}
 	return nil
@@ -154,7 +155,7 @@ func (c *Client) ValidatePersistentVolumeClaimSize(pvc *corev1.PersistentVolumeC
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("core.ValidatePersistentVolumeClaimSize")); err != nil {
 		return err
 	}
 	return nil
@@ -175,6 +176,9 @@ func (c *Client) GetPersistentVolumeClaim(pvcName string, namespace string) (*co
 		return nil, err
 	}
 
+	if indexer := c.pvcIndexer(namespace); indexer != nil {
+		return cachedPVC(indexer, pvcName, namespace)
+	}
 	return c.kubernetes.CoreV1().PersistentVolumeClaims(namespace).
 		Get(context.TODO(), pvcName, metav1.GetOptions{})
 }
@@ -309,6 +313,10 @@ func (c *Client) GetPVCsUsingStorageClass(scName string) ([]corev1.PersistentVol
 		return nil, err
 	}
 
+	if indexer := c.pvcIndexer(""); indexer != nil {
+		return c.cachedPVCsUsingStorageClass(indexer, scName)
+	}
+
 	var retList []corev1.PersistentVolumeClaim
 	pvcs, err := c.kubernetes.CoreV1().PersistentVolumeClaims("").List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -0,0 +1,341 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCopySizeLimitExceeded is returned by CopyToPod and CopyFromPod when the files are larger than
+// the MaxBytes of the request
+var ErrCopySizeLimitExceeded = errors.New("size limit of the copy exceeded")
+
+// CopyRequest is a request structure for the CopyToPod and CopyFromPod funcs
+type CopyRequest struct {
+	// PodName is the name of the pod
+	PodName string
+	// ContainerName is the name of the container. It can be empty if the pod has a single container.
+	ContainerName string
+	// Namespace is the namespace of the pod
+	Namespace string
+	// LocalPath is the file or directory on the local host
+	LocalPath string
+	// RemotePath is the file or directory in the container
+	RemotePath string
+	// MaxBytes is the maximum total size of the copied files. Zero means no limit.
+	MaxBytes int64
+}
+
+// CopyToPod copies the local file or directory to the given path in the container, like kubectl cp.
+// The files are streamed as a tar archive over exec, so the container must have tar. File modes are
+// preserved regardless of the umask of the container user; the files are owned by that user unless it
+// is root.
+func (c *Client) CopyToPod(ctx context.Context, req *CopyRequest) error {
+	if err := validateCopyRequest(req); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(req.LocalPath); err != nil {
+		return err
+	}
+
+	remotePath := path.Clean(req.RemotePath)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	tarDone := make(chan error, 1)
+	go func() {
+		err := writeTar(writer, req.LocalPath, path.Base(remotePath), req.MaxBytes)
+		writer.CloseWithError(err)
+		if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			// abort the extraction in the container
+			cancel()
+		}
+		tarDone <- err
+	}()
+
+	var stderr bytes.Buffer
+	res, err := c.ExecInPod(ctx, &ExecRequest{
+		Command:       []string{"tar", "-xmpf", "-", "-C", path.Dir(remotePath)},
+		PodName:       req.PodName,
+		ContainerName: req.ContainerName,
+		Namespace:     req.Namespace,
+		Stdin:         reader,
+		Stdout:        io.Discard,
+		Stderr:        &stderr,
+	})
+	// unblock the tar writer if the exec ended before reading all of it
+	reader.Close()
+	tarErr := <-tarDone
+	if tarErr != nil && !errors.Is(tarErr, io.ErrClosedPipe) {
+		return fmt.Errorf("failed to copy %s to %s/%s:%s: %w", req.LocalPath, req.Namespace, req.PodName, remotePath, tarErr)
+	}
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("failed to extract files in %s/%s: exit code %d: %s",
+			req.Namespace, req.PodName, res.ExitCode, stderr.String())
+	}
+	if tarErr != nil {
+		return fmt.Errorf("failed to copy %s to %s/%s:%s: the archive was not fully read",
+			req.LocalPath, req.Namespace, req.PodName, remotePath)
+	}
+	return nil
+}
+
+// CopyFromPod copies the file or directory at the given path in the container to the local path, like
+// kubectl cp. The files are streamed as a tar archive over exec, so the container must have tar. File
+// modes are preserved.
+func (c *Client) CopyFromPod(ctx context.Context, req *CopyRequest) error {
+	if err := validateCopyRequest(req); err != nil {
+		return err
+	}
+
+	remotePath := path.Clean(req.RemotePath)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	type execResult struct {
+		res *ExecResult
+		err error
+	}
+	execDone := make(chan execResult, 1)
+	var stderr bytes.Buffer
+	go func() {
+		res, err := c.ExecInPod(ctx, &ExecRequest{
+			Command:       []string{"tar", "-cf", "-", "-C", path.Dir(remotePath), path.Base(remotePath)},
+			PodName:       req.PodName,
+			ContainerName: req.ContainerName,
+			Namespace:     req.Namespace,
+			Stdout:        writer,
+			Stderr:        &stderr,
+		})
+		writer.CloseWithError(err)
+		execDone <- execResult{res: res, err: err}
+	}()
+
+	untarErr := readTar(reader, req.LocalPath, path.Base(remotePath), req.MaxBytes)
+	if untarErr != nil {
+		// abort the archive creation in the container
+		cancel()
+	}
+	reader.CloseWithError(untarErr)
+	result := <-execDone
+
+	if untarErr != nil {
+		if result.err != nil && !errors.Is(result.err, context.Canceled) {
+			// the archive was cut short by the exec failure
+			return result.err
+		}
+		return fmt.Errorf("failed to copy %s/%s:%s to %s: %w", req.Namespace, req.PodName, remotePath, req.LocalPath, untarErr)
+	}
+	if result.err != nil {
+		return result.err
+	}
+	if result.res.ExitCode != 0 {
+		return fmt.Errorf("failed to archive files in %s/%s: exit code %d: %s",
+			req.Namespace, req.PodName, result.res.ExitCode, stderr.String())
+	}
+	return nil
+}
+
+func validateCopyRequest(req *CopyRequest) error {
+	if req == nil {
+		return fmt.Errorf("copy request cannot be nil")
+	}
+	if req.LocalPath == "" || req.RemotePath == "" {
+		return fmt.Errorf("local and remote paths cannot be empty")
+	}
+	if req.MaxBytes < 0 {
+		return fmt.Errorf("max bytes cannot be negative")
+	}
+	return nil
+}
+
+// writeTar writes a tar archive of the local file or directory to w. The entries are named after the
+// given base name instead of the local name.
+func writeTar(w io.Writer, localPath, base string, maxBytes int64) error {
+	tw := tar.NewWriter(w)
+	var total int64
+	err := filepath.Walk(localPath, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localPath, file)
+		if err != nil {
+			return err
+		}
+		name := path.Join(base, filepath.ToSlash(rel))
+
+		var link string
+		switch {
+		case info.Mode().IsRegular():
+			total += info.Size()
+			if maxBytes > 0 && total > maxBytes {
+				return ErrCopySizeLimitExceeded
+			}
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		case !info.IsDir():
+			// devices, sockets and pipes cannot be copied
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, info.Size())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts the tar archive read from r to the local path. The entries must be named after the
+// given base name, which is replaced by the local path. Entries that would be extracted outside of the
+// local path, or through a symlink extracted before, are rejected.
+func readTar(r io.Reader, localPath, base string, maxBytes int64) error {
+	tr := tar.NewReader(r)
+	var total int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if name != base && !strings.HasPrefix(name, base+"/") {
+			return fmt.Errorf("unexpected entry %q in archive", header.Name)
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, base), "/")
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return fmt.Errorf("entry %q in archive is outside of %s", header.Name, base)
+		}
+		if err := checkExtractPath(localPath, rel, false); err != nil {
+			return fmt.Errorf("entry %q in archive: %w", header.Name, err)
+		}
+		target := filepath.Join(localPath, filepath.FromSlash(rel))
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			total += header.Size
+			if maxBytes > 0 && total > maxBytes {
+				return ErrCopySizeLimitExceeded
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			linkTarget := path.Clean(path.Join(path.Dir(name), header.Linkname))
+			if path.IsAbs(header.Linkname) || (linkTarget != base && !strings.HasPrefix(linkTarget, base+"/")) {
+				return fmt.Errorf("symlink %q in archive points outside of %s", header.Name, base)
+			}
+			// the link may still leave the local path once the symlinks it goes through are followed,
+			// e.g. y -> x/.. with x -> .
+			if rel != "" {
+				if err := checkExtractPath(localPath, path.Dir(rel)+"/"+header.Linkname, true); err != nil {
+					return fmt.Errorf("symlink %q in archive: %w", header.Name, err)
+				}
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			// hard links, devices and the like are not copied
+		}
+	}
+}
+
+// checkExtractPath returns an error if the given slash separated path, relative to the local path, goes
+// through a symlink which exists on disk, or leaves the local path. The last element of the path may be
+// a symlink if allowLastSymlink is true.
+func checkExtractPath(localPath, rel string, allowLastSymlink bool) error {
+	elements := strings.Split(rel, "/")
+	var parts []string
+	for i, element := range elements {
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			if len(parts) == 0 {
+				return fmt.Errorf("path %s is outside of %s", rel, localPath)
+			}
+			parts = parts[:len(parts)-1]
+			continue
+		}
+		parts = append(parts, element)
+
+		info, err := os.Lstat(filepath.Join(localPath, filepath.Join(parts...)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 && !(allowLastSymlink && i == len(elements)-1) {
+			return fmt.Errorf("path %s goes through the symlink %s", rel, path.Join(parts...))
+		}
+	}
+	return nil
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// the mode given to OpenFile is not used if the file exists and is subject to the umask
+	return os.Chmod(target, mode)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyTarRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "script.sh"), []byte("#!/bin/sh\necho hi\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "data"), []byte("data"), 0600))
+	require.NoError(t, os.Symlink("sub/data", filepath.Join(src, "link")))
+
+	var archive bytes.Buffer
+	require.NoError(t, writeTar(&archive, src, "remote", 0))
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	require.NoError(t, readTar(&archive, dst, "remote", 0))
+
+	info, err := os.Stat(filepath.Join(dst, "script.sh"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	info, err = os.Stat(filepath.Join(dst, "sub"))
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+	data, err := os.ReadFile(filepath.Join(dst, "sub", "data"))
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+	link, err := os.Readlink(filepath.Join(dst, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "sub/data", link)
+
+	// a single file is copied to the given path
+	archive.Reset()
+	require.NoError(t, writeTar(&archive, filepath.Join(src, "script.sh"), "run.sh", 0))
+	file := filepath.Join(t.TempDir(), "run.sh")
+	require.NoError(t, readTar(&archive, file, "run.sh", 0))
+	data, err = os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho hi\n", string(data))
+}
+
+func TestCopyTarSizeLimit(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a"), make([]byte, 10), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "b"), make([]byte, 10), 0644))
+
+	var archive bytes.Buffer
+	require.ErrorIs(t, writeTar(&archive, src, "remote", 15), ErrCopySizeLimitExceeded)
+
+	archive.Reset()
+	require.NoError(t, writeTar(&archive, src, "remote", 20))
+	require.ErrorIs(t, readTar(&archive, t.TempDir(), "remote", 15), ErrCopySizeLimitExceeded)
+}
+
+func TestCopyTarRejectsEscapingEntries(t *testing.T) {
+	newArchive := func(headers ...*tar.Header) *bytes.Buffer {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for _, header := range headers {
+			require.NoError(t, tw.WriteHeader(header))
+		}
+		require.NoError(t, tw.Close())
+		return &archive
+	}
+
+	dst := t.TempDir()
+	err := readTar(newArchive(&tar.Header{Name: "remote/../../etc/passwd", Typeflag: tar.TypeReg, Mode: 0644}), dst, "remote", 0)
+	require.Error(t, err)
+	err = readTar(newArchive(&tar.Header{Name: "other/file", Typeflag: tar.TypeReg, Mode: 0644}), dst, "remote", 0)
+	require.Error(t, err)
+	err = readTar(newArchive(&tar.Header{Name: "remote/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}), dst, "remote", 0)
+	require.Error(t, err)
+	err = readTar(newArchive(&tar.Header{Name: "remote/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}), dst, "remote", 0)
+	require.Error(t, err)
+
+	// a symlink going through another one cannot be used to write outside of the local path
+	parent := t.TempDir()
+	local := filepath.Join(parent, "local")
+	err = readTar(newArchive(
+		&tar.Header{Name: "remote/x", Typeflag: tar.TypeSymlink, Linkname: "."},
+		&tar.Header{Name: "remote/y", Typeflag: tar.TypeSymlink, Linkname: "x/.."},
+		&tar.Header{Name: "remote/y/evil", Typeflag: tar.TypeReg, Mode: 0644},
+	), local, "remote", 0)
+	require.Error(t, err)
+	_, err = os.Lstat(filepath.Join(parent, "evil"))
+	require.True(t, os.IsNotExist(err), "Expected no file to be written outside of the local path")
+	// nor can a file be written through a symlink, even one pointing inside the local path
+	err = readTar(newArchive(
+		&tar.Header{Name: "remote/sub", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "remote/link", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+		&tar.Header{Name: "remote/link/file", Typeflag: tar.TypeReg, Mode: 0644},
+	), filepath.Join(parent, "local2"), "remote", 0)
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(dst)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"k8s.io/client-go/util/exec"
+)
+
+// ExecRequest is a request structure for the ExecInPod func
+type ExecRequest struct {
+	// Command is the command to run and its arguments
+	Command []string
+	// PodName is the name of the pod
+	PodName string
+	// ContainerName is the name of the container. It can be empty if the pod has a single container.
+	ContainerName string
+	// Namespace is the namespace of the pod
+	Namespace string
+	// Stdin is sent to the standard input of the command if set. The standard input of the command is
+	// closed once Stdin returns io.EOF.
+	Stdin io.Reader
+	// Stdout receives the standard output of the command if set. Otherwise the standard output is
+	// returned in the ExecResult.
+	Stdout io.Writer
+	// Stderr receives the standard error of the command if set. Otherwise the standard error is
+	// returned in the ExecResult. It is not used with TTY.
+	Stderr io.Writer
+	// TTY allocates a terminal for the command
+	TTY bool
+	// TerminalSizeQueue sends the terminal size changes to the TTY of the command
+	TerminalSizeQueue remotecommand.TerminalSizeQueue
+}
+
+// ExecResult is the result of a command run with ExecInPod
+type ExecResult struct {
+	// Stdout is the standard output of the command if ExecRequest.Stdout was not set
+	Stdout string
+	// Stderr is the standard error of the command if ExecRequest.Stderr was not set
+	Stderr string
+	// ExitCode is the exit code of the command
+	ExitCode int
+	// Duration is the time it took to run the command
+	Duration time.Duration
+}
+
+// errSPDYUpgradeFailed is returned when the API server, or a proxy in front of it, rejects the SPDY
+// protocol for the exec connection
+var errSPDYUpgradeFailed = errors.New("failed to upgrade the connection to SPDY")
+
+// ExecInPod runs the given command in the given pod. The error is nil if the command ran, regardless
+// of its exit code which is returned in the ExecResult. The command is aborted when ctx is done.
+// The command runs over SPDY; if the SPDY protocol is rejected, it runs over WebSocket, where Stdin
+// requires an API server supporting the v5 exec protocol. Other errors of the API server, e.g.
+// Forbidden, are returned as is.
+func (c *Client) ExecInPod(ctx context.Context, req *ExecRequest) (*ExecResult, error) {
+	if c == nil || req == nil {
+		return nil, fmt.Errorf("exec request cannot be nil")
+	}
+	if len(req.Command) == 0 {
+		return nil, fmt.Errorf("exec command cannot be empty")
+	}
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+	if c.config == nil {
+		return nil, fmt.Errorf("exec requires a client created from a rest config")
+	}
+
+	containerName := req.ContainerName
+	if len(containerName) == 0 {
+		pod, err := c.kubernetes.CoreV1().Pods(req.Namespace).Get(ctx, req.PodName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pod.Spec.Containers) != 1 {
+			return nil, fmt.Errorf("could not determine which container to use")
+		}
+
+		containerName = pod.Spec.Containers[0].Name
+	}
+
+	var stdout, stderr bytes.Buffer
+	opts := remotecommand.StreamOptions{
+		Stdin:             req.Stdin,
+		Stdout:            req.Stdout,
+		Stderr:            req.Stderr,
+		Tty:               req.TTY,
+		TerminalSizeQueue: req.TerminalSizeQueue,
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = &stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = &stderr
+	}
+	if req.TTY {
+		// stderr is merged into stdout by the terminal
+		opts.Stderr = nil
+	}
+
+	execURL := c.kubernetes.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(req.PodName).
+		Namespace(req.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   req.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    true,
+			Stderr:    opts.Stderr != nil,
+			TTY:       req.TTY,
+		}, scheme.ParameterCodec).URL()
+
+	start := time.Now()
+	err := c.streamSPDY(ctx, execURL, opts)
+	if errors.Is(err, errSPDYUpgradeFailed) {
+		logrus.WithError(err).Debugf("falling back to WebSocket to exec in pod %s/%s", req.Namespace, req.PodName)
+		err = c.streamWebSocket(ctx, execURL, opts)
+	}
+
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	var exitErr exec.CodeExitError
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.Code
+		return result, nil
+	}
+	return result, err
+}
+
+// streamSPDY runs the exec stream over SPDY until it ends or ctx is done.
+func (c *Client) streamSPDY(ctx context.Context, execURL *url.URL, opts remotecommand.StreamOptions) error {
+	transport, upgrader, err := spdy.RoundTripperFor(c.config)
+	if err != nil {
+		return fmt.Errorf("failed to init executor: %v", err)
+	}
+	cancelable := &cancelableUpgrader{Upgrader: upgrader}
+	executor, err := remotecommand.NewSPDYExecutorForTransports(transport, cancelable, http.MethodPost, execURL)
+	if err != nil {
+		return fmt.Errorf("failed to init executor: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(opts)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		cancelable.close()
+		// wait for the stream so that nothing is written to the caller's writers after we return
+		<-done
+		return ctx.Err()
+	}
+	if cancelable.spdyRejected() {
+		return fmt.Errorf("%w: %v", errSPDYUpgradeFailed, err)
+	}
+	return err
+}
+
+// cancelableUpgrader keeps the SPDY connection created by the executor so that it can be closed when
+// the context of the exec is done.
+type cancelableUpgrader struct {
+	spdy.Upgrader
+
+	mu     sync.Mutex
+	conn   httpstream.Connection
+	closed bool
+	// rejected is set if the SPDY protocol was rejected, rather than the exec request itself
+	rejected bool
+}
+
+func (u *cancelableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err != nil {
+		u.rejected = isSPDYRejected(resp.StatusCode, err)
+		return nil, err
+	}
+	if u.closed {
+		conn.Close()
+		return nil, context.Canceled
+	}
+	u.conn = conn
+	return conn, nil
+}
+
+func (u *cancelableUpgrader) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
+func (u *cancelableUpgrader) spdyRejected() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.rejected
+}
+
+// isSPDYRejected returns true if the response to the upgrade request shows that the SPDY protocol is not
+// supported: the request was not upgraded to SPDY, or it was refused by something other than the API
+// server, e.g. a proxy, as a bad request or a protocol it does not implement. Errors returned by the API
+// server, like Unauthorized, Forbidden or NotFound, do not mean SPDY is rejected.
+func isSPDYRejected(statusCode int, err error) bool {
+	if statusCode < http.StatusMultipleChoices {
+		return true
+	}
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return false
+	}
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed, http.StatusUpgradeRequired, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	remotecommandconsts "k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// newWebSocketExecServer returns a server which rejects SPDY and runs the exec requests over WebSocket.
+// The "cat" command echoes stdin and the terminal sizes to stdout and exits with code 3, the "sleep"
+// command waits for the client to go away. The v5 and v4 protocols are offered unless others are given.
+func newWebSocketExecServer(t *testing.T, protocols ...string) *httptest.Server {
+	if len(protocols) == 0 {
+		protocols = []string{streamProtocolV5Name, remotecommandconsts.StreamProtocolV4Name}
+	}
+	upgrader := websocket.Upgrader{Subprotocols: protocols}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/namespaces/ns1/pods/pod1/exec") {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(strings.ToLower(r.Header.Get("Upgrade")), "spdy") {
+			http.Error(w, "SPDY is not supported", http.StatusBadRequest)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var stdin []byte
+		var sizes []remotecommand.TerminalSize
+		for r.URL.Query().Get("stdin") == "true" {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if data[0] == wsCloseChannel && data[1] == wsStdinChannel {
+				break
+			}
+			switch data[0] {
+			case wsStdinChannel:
+				stdin = append(stdin, data[1:]...)
+			case wsResizeChannel:
+				var size remotecommand.TerminalSize
+				if err := json.Unmarshal(data[1:], &size); err == nil {
+					sizes = append(sizes, size)
+				}
+			}
+		}
+
+		if r.URL.Query().Get("command") == "sleep" {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+
+		write := func(channel byte, data []byte) {
+			require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...)))
+		}
+		write(wsStdoutChannel, stdin)
+		for _, size := range sizes {
+			write(wsStdoutChannel, []byte(fmt.Sprintf(" %dx%d", size.Width, size.Height)))
+		}
+		write(wsStderrChannel, []byte("exiting"))
+		status, err := json.Marshal(metav1.Status{
+			Status: metav1.StatusFailure,
+			Reason: remotecommandconsts.NonZeroExitCodeReason,
+			Details: &metav1.StatusDetails{
+				Causes: []metav1.StatusCause{{Type: remotecommandconsts.ExitCodeCauseType, Message: "3"}},
+			},
+		})
+		require.NoError(t, err)
+		write(wsErrorChannel, status)
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+}
+
+type testSizeQueue struct {
+	sizes []remotecommand.TerminalSize
+}
+
+func (q *testSizeQueue) Next() *remotecommand.TerminalSize {
+	if len(q.sizes) == 0 {
+		return nil
+	}
+	size := q.sizes[0]
+	q.sizes = q.sizes[1:]
+	return &size
+}
+
+func TestExecInPodWebSocketFallback(t *testing.T) {
+	server := newWebSocketExecServer(t)
+	defer server.Close()
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	res, err := client.ExecInPod(context.Background(), &ExecRequest{
+		Command:       []string{"cat"},
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+		Stdin:         strings.NewReader("hello"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "hello", res.Stdout)
+	require.Equal(t, "exiting", res.Stderr)
+	require.Equal(t, 3, res.ExitCode)
+	require.True(t, res.Duration > 0)
+
+	// the exit code is still returned as an error by RunCommandInPodEx
+	var stdout strings.Builder
+	err = client.RunCommandInPodEx(&RunCommandInPodExRequest{
+		Command:       []string{"cat"},
+		PODName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+		Stdin:         strings.NewReader("hello"),
+		Stdout:        &stdout,
+	})
+	require.EqualError(t, err, "command terminated with exit code 3")
+	require.Equal(t, "hello", stdout.String())
+}
+
+func TestExecInPodWebSocketV4(t *testing.T) {
+	server := newWebSocketExecServer(t, remotecommandconsts.StreamProtocolV4Name)
+	defer server.Close()
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	// the end of stdin cannot be sent with v4
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.ExecInPod(ctx, &ExecRequest{
+		Command:       []string{"cat"},
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+		Stdin:         strings.NewReader("hello"),
+	})
+	require.Error(t, err)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), streamProtocolV5Name)
+
+	res, err := client.ExecInPod(ctx, &ExecRequest{
+		Command:       []string{"cat"},
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, res.ExitCode)
+}
+
+func TestExecInPodResize(t *testing.T) {
+	server := newWebSocketExecServer(t)
+	defer server.Close()
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	stdin, stdinWriter := io.Pipe()
+	queue := &testSizeQueue{sizes: []remotecommand.TerminalSize{{Width: 80, Height: 24}}}
+	go func() {
+		// give the resize time to be sent before closing stdin
+		time.Sleep(200 * time.Millisecond)
+		stdinWriter.Close()
+	}()
+	res, err := client.ExecInPod(context.Background(), &ExecRequest{
+		Command:           []string{"cat"},
+		PodName:           "pod1",
+		ContainerName:     "c1",
+		Namespace:         "ns1",
+		Stdin:             stdin,
+		TTY:               true,
+		TerminalSizeQueue: queue,
+	})
+	require.NoError(t, err)
+	require.Equal(t, " 80x24", res.Stdout)
+	require.Empty(t, res.Stderr, "Expected no stderr with a TTY")
+}
+
+func TestExecInPodContextCancelled(t *testing.T) {
+	server := newWebSocketExecServer(t)
+	defer server.Close()
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = client.ExecInPod(ctx, &ExecRequest{
+		Command:       []string{"sleep"},
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestExecInPodForbidden(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		status := apierrors.NewForbidden(schema.GroupResource{Resource: "pods/exec"}, "pod1", fmt.Errorf("RBAC denied"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		require.NoError(t, json.NewEncoder(w).Encode(status.ErrStatus))
+	}))
+	defer server.Close()
+	client, err := NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.ExecInPod(context.Background(), &ExecRequest{
+		Command:       []string{"cat"},
+		PodName:       "pod1",
+		ContainerName: "c1",
+		Namespace:     "ns1",
+	})
+	require.Error(t, err)
+	require.True(t, apierrors.IsForbidden(err), "Expected the Forbidden error of the API server, got %v", err)
+	require.Contains(t, err.Error(), "RBAC denied")
+	require.EqualValues(t, 1, requests.Load(), "Expected no WebSocket retry")
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	remotecommandconsts "k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+const (
+	// streamProtocolV5Name is the WebSocket subprotocol which adds closing the stdin stream to v4
+	streamProtocolV5Name = "v5.channel.k8s.io"
+
+	// channels of the WebSocket exec protocol; every message starts with the channel
+	wsStdinChannel  = 0
+	wsStdoutChannel = 1
+	wsStderrChannel = 2
+	wsErrorChannel  = 3
+	wsResizeChannel = 4
+	// wsCloseChannel is used to close one of the other channels with the v5 protocol
+	wsCloseChannel = 255
+
+	// wsHandshakeTimeout is the maximum time to wait for the WebSocket upgrade
+	wsHandshakeTimeout = 30 * time.Second
+	// wsStdinBufferSize is the maximum size of a stdin message
+	wsStdinBufferSize = 32 * 1024
+)
+
+// streamWebSocket runs the exec stream over WebSocket until it ends or ctx is done. The error channel
+// of the v4 and v5 protocols carries the same status as with SPDY, so exit codes are returned as
+// exec.CodeExitError in the same way. Stdin requires the v5 protocol: with v4 the end of stdin cannot
+// be sent, so a command reading stdin until its end would never exit.
+func (c *Client) streamWebSocket(ctx context.Context, execURL *url.URL, opts remotecommand.StreamOptions) error {
+	conn, protocol, err := c.dialWebSocket(ctx, execURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if opts.Stdin != nil && protocol != streamProtocolV5Name {
+		return fmt.Errorf("stdin is not supported by the %q WebSocket protocol of the API server, %q is required",
+			protocol, streamProtocolV5Name)
+	}
+
+	ws := &wsExecStream{conn: conn}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// unblocks the reader below
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	if opts.Stdin != nil {
+		go ws.copyStdin(opts.Stdin)
+	}
+	if opts.Tty && opts.TerminalSizeQueue != nil {
+		go ws.handleResizes(opts.TerminalSizeQueue)
+	}
+
+	err = ws.readOutput(opts.Stdout, opts.Stderr)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// dialWebSocket opens the WebSocket connection for the given exec URL. The upgrade request is sent
+// through the wrappers of the rest config so that it is authenticated like any other request.
+func (c *Client) dialWebSocket(ctx context.Context, execURL *url.URL) (*websocket.Conn, string, error) {
+	tlsConfig, err := rest.TLSConfigFor(c.config)
+	if err != nil {
+		return nil, "", err
+	}
+	proxy := http.ProxyFromEnvironment
+	if c.config.Proxy != nil {
+		proxy = c.config.Proxy
+	}
+
+	rt := &wsRoundTripper{
+		dialer: &websocket.Dialer{
+			Proxy:            proxy,
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: wsHandshakeTimeout,
+			Subprotocols:     []string{streamProtocolV5Name, remotecommandconsts.StreamProtocolV4Name},
+		},
+	}
+	wrapper, err := rest.HTTPWrappersForConfig(c.config, rt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, execURL.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := wrapper.RoundTrip(req)
+	if err != nil {
+		return nil, "", err
+	}
+	resp.Body.Close()
+	return rt.conn, rt.conn.Subprotocol(), nil
+}
+
+// wsRoundTripper dials the WebSocket connection for the upgrade request it is given.
+type wsRoundTripper struct {
+	dialer *websocket.Dialer
+	conn   *websocket.Conn
+}
+
+func (rt *wsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	wsURL := *req.URL
+	switch wsURL.Scheme {
+	case "https":
+		wsURL.Scheme = "wss"
+	case "http":
+		wsURL.Scheme = "ws"
+	}
+
+	conn, resp, err := rt.dialer.DialContext(req.Context(), wsURL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("unable to upgrade connection to WebSocket: %v (%s)", err, resp.Status)
+		}
+		return nil, fmt.Errorf("unable to upgrade connection to WebSocket: %v", err)
+	}
+	rt.conn = conn
+	return resp, nil
+}
+
+// wsExecStream multiplexes the exec streams over a WebSocket connection.
+type wsExecStream struct {
+	conn *websocket.Conn
+	// writeMu serializes the writes to the connection, which does not support concurrent writers
+	writeMu sync.Mutex
+}
+
+func (ws *wsExecStream) write(channel byte, data []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	return ws.conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, data...))
+}
+
+// copyStdin sends stdin to the server and closes the stdin channel once it ends.
+func (ws *wsExecStream) copyStdin(stdin io.Reader) {
+	buf := make([]byte, wsStdinBufferSize)
+	for {
+		n, err := stdin.Read(buf)
+		if n > 0 {
+			if writeErr := ws.write(wsStdinChannel, buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				_ = ws.write(wsCloseChannel, []byte{wsStdinChannel})
+			}
+			return
+		}
+	}
+}
+
+func (ws *wsExecStream) handleResizes(queue remotecommand.TerminalSizeQueue) {
+	for size := queue.Next(); size != nil; size = queue.Next() {
+		data, err := json.Marshal(size)
+		if err != nil {
+			return
+		}
+		if err := ws.write(wsResizeChannel, data); err != nil {
+			return
+		}
+	}
+}
+
+// readOutput copies the output channels to the given writers until the server closes the connection,
+// and returns the error sent on the error channel.
+func (ws *wsExecStream) readOutput(stdout, stderr io.Writer) error {
+	var errorMessage []byte
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) || errors.Is(err, io.EOF) {
+				return decodeExecError(errorMessage)
+			}
+			return err
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		switch data[0] {
+		case wsStdoutChannel:
+			if stdout != nil {
+				if _, err := stdout.Write(data[1:]); err != nil {
+					return err
+				}
+			}
+		case wsStderrChannel:
+			if stderr != nil {
+				if _, err := stderr.Write(data[1:]); err != nil {
+					return err
+				}
+			}
+		case wsErrorChannel:
+			errorMessage = append(errorMessage, data[1:]...)
+		}
+	}
+}
+
+// decodeExecError converts the status sent on the error channel to an error. A non-zero exit code of
+// the command is returned as exec.CodeExitError.
+func decodeExecError(message []byte) error {
+	if len(message) == 0 {
+		return nil
+	}
+
+	status := metav1.Status{}
+	if err := json.Unmarshal(message, &status); err != nil {
+		return fmt.Errorf("error stream protocol error: %v in %q", err, string(message))
+	}
+	switch status.Status {
+	case metav1.StatusSuccess:
+		return nil
+	case metav1.StatusFailure:
+		if status.Reason != remotecommandconsts.NonZeroExitCodeReason {
+			return errors.New(status.Message)
+		}
+		if status.Details != nil {
+			for _, cause := range status.Details.Causes {
+				if cause.Type != remotecommandconsts.ExitCodeCauseType {
+					continue
+				}
+				rc, err := strconv.ParseUint(cause.Message, 10, 8)
+				if err != nil {
+					return fmt.Errorf("error stream protocol error: invalid exit code value %q", cause.Message)
+				}
+				return exec.CodeExitError{
+					Err:  fmt.Errorf("command terminated with exit code %d", rc),
+					Code: int(rc),
+				}
+			}
+		}
+		return fmt.Errorf("error stream protocol error: no %s cause given", remotecommandconsts.ExitCodeCauseType)
+	}
+	return errors.New("error stream protocol error: unknown error")
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxPodLogLineSize is the maximum size of a log line passed to a PodLogHandler. Longer lines are
+// split; only the first part has a timestamp.
+const maxPodLogLineSize = 1024 * 1024
+
+// PodLogLine is a single line of the logs of a container passed to a PodLogHandler.
+type PodLogLine struct {
+	// Namespace is the namespace of the pod
+	Namespace string
+	// PodName is the name of the pod
+	PodName string
+	// ContainerName is the name of the container
+	ContainerName string
+	// Timestamp is the time at which the line was logged. It is zero if the runtime did not
+	// provide a timestamp.
+	Timestamp time.Time
+	// Line is the log line without the trailing newline
+	Line string
+}
+
+// String returns the line prefixed with the pod, container and timestamp.
+func (l PodLogLine) String() string {
+	if l.Timestamp.IsZero() {
+		return fmt.Sprintf("[%s/%s] %s", l.PodName, l.ContainerName, l.Line)
+	}
+	return fmt.Sprintf("[%s/%s] %s %s", l.PodName, l.ContainerName, l.Timestamp.Format(time.RFC3339Nano), l.Line)
+}
+
+// PodLogHandler handles the log lines followed by FollowPodLogs. It is never called concurrently.
+type PodLogHandler func(line PodLogLine)
+
+// StreamPodLog returns a stream of the logs of the given pod. Set Follow in the podLogOptions to keep
+// the stream open while the container runs. The stream is closed when ctx is done; the caller must
+// close it once done reading.
+func (c *Client) StreamPodLog(ctx context.Context, podName, ns string, podLogOptions *corev1.PodLogOptions) (io.ReadCloser, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	return c.kubernetes.CoreV1().Pods(ns).GetLogs(podName, podLogOptions).Stream(ctx)
+}
+
+// FollowPodLogs follows the logs of every container, init containers included, of every pod in the
+// given namespace matching the label selector until ctx is done. Pods created after the call are
+// followed as soon as their containers start. Lines of all the containers are passed to the handler
+// as they are logged. Ephemeral containers are not followed. It returns the ctx error once ctx is done,
+// or the error of the pod watch if it fails.
+func (c *Client) FollowPodLogs(ctx context.Context, labelSelector map[string]string, ns string, handler PodLogHandler) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	f := &podLogFollower{
+		client:    c,
+		namespace: ns,
+		handler:   handler,
+		following: map[string]bool{},
+		lastSeen:  map[types.UID]map[string]time.Time{},
+	}
+	defer f.wg.Wait()
+
+	listOptions := metav1.ListOptions{
+		LabelSelector: labels.FormatLabels(labelSelector),
+	}
+	source := schedwatch.ForClient[*corev1.PodList](c.kubernetes.CoreV1().Pods(ns))
+	w, err := schedwatch.Start(ctx, source, func(event watch.Event) error {
+		f.handlePodEvent(ctx, event)
+		return nil
+	}, &schedwatch.Options{ListOptions: listOptions})
+	if err != nil {
+		return err
+	}
+
+	<-w.Done()
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// podLogFollower tracks the containers followed by FollowPodLogs.
+type podLogFollower struct {
+	client    *Client
+	namespace string
+	handler   PodLogHandler
+
+	wg        sync.WaitGroup
+	handlerMu sync.Mutex
+	mu        sync.Mutex
+	// following has the containers with a running log stream
+	following map[string]bool
+	// lastSeen has the timestamp of the last line passed to the handler for every container of the
+	// pods with a followed container, so that lines are not repeated when the log stream of a
+	// restarted container is opened again. Pods are removed once they are deleted.
+	lastSeen map[types.UID]map[string]time.Time
+}
+
+// handlePodEvent starts following the running containers of the added and modified pods and forgets
+// the deleted ones.
+func (f *podLogFollower) handlePodEvent(ctx context.Context, event watch.Event) {
+	pod, ok := event.Object.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		f.followPod(ctx, pod)
+	case watch.Deleted:
+		f.forgetPod(pod.UID)
+	}
+}
+
+// followPod starts following the logs of the containers of the given pod that have started and are
+// not followed yet.
+func (f *podLogFollower) followPod(ctx context.Context, pod *corev1.Pod) {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Running == nil && status.State.Terminated == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", pod.UID, pod.Name, status.Name)
+		f.mu.Lock()
+		if f.following[key] {
+			f.mu.Unlock()
+			continue
+		}
+		f.following[key] = true
+		if f.lastSeen[pod.UID] == nil {
+			f.lastSeen[pod.UID] = map[string]time.Time{}
+		}
+		sinceTime := f.lastSeen[pod.UID][status.Name]
+		f.mu.Unlock()
+
+		f.wg.Add(1)
+		go func(uid types.UID, podName, container string) {
+			defer f.wg.Done()
+			lastSeen, err := f.followContainer(ctx, podName, container, sinceTime)
+			if err != nil && ctx.Err() == nil {
+				logrus.WithError(err).Debugf("stopped following logs of %s/%s/%s", f.namespace, podName, container)
+			}
+
+			f.mu.Lock()
+			delete(f.following, key)
+			// the pod is gone if it was deleted while the stream was open
+			if f.lastSeen[uid] != nil {
+				f.lastSeen[uid][container] = lastSeen
+			}
+			f.mu.Unlock()
+
+			// deletions are missed while the pod watch is re-established
+			if ctx.Err() == nil && !f.podExists(ctx, uid, podName) {
+				f.forgetPod(uid)
+			}
+		}(pod.UID, pod.Name, status.Name)
+	}
+}
+
+// forgetPod drops the timestamps of the containers of the given pod once it is deleted.
+func (f *podLogFollower) forgetPod(uid types.UID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.lastSeen, uid)
+}
+
+// podExists returns false if the pod with the given name is not found or was replaced by another
+// pod with the same name.
+func (f *podLogFollower) podExists(ctx context.Context, uid types.UID, podName string) bool {
+	pod, err := f.client.kubernetes.CoreV1().Pods(f.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return !apierrors.IsNotFound(err)
+	}
+	return pod.UID == uid
+}
+
+// followContainer passes the log lines of the container logged after sinceTime to the handler until
+// the log stream ends. It returns the timestamp of the last line passed to the handler.
+func (f *podLogFollower) followContainer(ctx context.Context, podName, container string, sinceTime time.Time) (time.Time, error) {
+	opts := &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !sinceTime.IsZero() {
+		since := metav1.NewTime(sinceTime)
+		opts.SinceTime = &since
+	}
+
+	stream, err := f.client.StreamPodLog(ctx, podName, f.namespace, opts)
+	if err != nil {
+		return sinceTime, err
+	}
+	defer stream.Close()
+
+	lastSeen := sinceTime
+	reader := bufio.NewReaderSize(stream, maxPodLogLineSize)
+	var continued, skipped bool
+	for {
+		data, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			return lastSeen, nil
+		}
+		if err != nil {
+			return lastSeen, err
+		}
+
+		var timestamp time.Time
+		line := string(data)
+		if !continued {
+			timestamp, line = parsePodLogLine(line)
+			// SinceTime has a precision of seconds; skip the lines we have seen already
+			skipped = !timestamp.IsZero() && !timestamp.After(sinceTime)
+			if !skipped && !timestamp.IsZero() {
+				lastSeen = timestamp
+			}
+		}
+		continued = isPrefix
+		if skipped {
+			continue
+		}
+
+		f.handlerMu.Lock()
+		f.handler(PodLogLine{
+			Namespace:     f.namespace,
+			PodName:       podName,
+			ContainerName: container,
+			Timestamp:     timestamp,
+			Line:          line,
+		})
+		f.handlerMu.Unlock()
+	}
+}
+
+// parsePodLogLine splits a log line requested with timestamps into its timestamp and the line itself.
+func parsePodLogLine(text string) (time.Time, string) {
+	idx := strings.IndexByte(text, ' ')
+	if idx < 0 {
+		return time.Time{}, text
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, text[:idx])
+	if err != nil {
+		return time.Time{}, text
+	}
+	return timestamp, text[idx+1:]
+}
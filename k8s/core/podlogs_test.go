@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRunningPod(name string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ns1",
+			UID:       types.UID("uid-" + name),
+			Labels:    map[string]string{"app": "logs"},
+		},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  container,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		})
+	}
+	return pod
+}
+
+func TestStreamPodLog(t *testing.T) {
+	client := New(fake.NewSimpleClientset(newRunningPod("pod1", "c1")))
+
+	stream, err := client.StreamPodLog(context.Background(), "pod1", "ns1", &corev1.PodLogOptions{})
+	require.NoError(t, err)
+	defer stream.Close()
+	data, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.Equal(t, "fake logs", string(data))
+}
+
+func TestFollowPodLogs(t *testing.T) {
+	other := newRunningPod("other", "c1")
+	other.Labels = nil
+	client := New(fake.NewSimpleClientset(newRunningPod("pod1", "c1", "c2"), other))
+
+	lines := make(chan PodLogLine, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.FollowPodLogs(ctx, map[string]string{"app": "logs"}, "ns1", func(line PodLogLine) {
+			lines <- line
+		})
+	}()
+
+	received := func(count int) map[string]PodLogLine {
+		got := map[string]PodLogLine{}
+		for len(got) < count {
+			select {
+			case line := <-lines:
+				got[line.PodName+"/"+line.ContainerName] = line
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for log lines, got %v", got)
+			}
+		}
+		return got
+	}
+	got := received(2)
+	require.Contains(t, got, "pod1/c1")
+	require.Contains(t, got, "pod1/c2")
+	require.Equal(t, "fake logs", got["pod1/c1"].Line)
+	require.Equal(t, "ns1", got["pod1/c1"].Namespace)
+	require.Equal(t, "[pod1/c1] fake logs", got["pod1/c1"].String())
+
+	// pods created later are picked up as well
+	pod2 := newRunningPod("pod2", "c1", "c2")
+	pod2.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "init",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}},
+	}}
+	_, err := client.kubernetes.CoreV1().Pods("ns1").Create(context.TODO(), pod2, metav1.CreateOptions{})
+	require.NoError(t, err)
+	got = received(3)
+	require.Contains(t, got, "pod2/c1")
+	require.Contains(t, got, "pod2/c2")
+	require.Contains(t, got, "pod2/init", "Expected the init containers to be followed")
+
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+	require.Empty(t, lines)
+}
+
+func TestFollowPodLogsForgetsDeletedPods(t *testing.T) {
+	pod1, pod2 := newRunningPod("pod1", "c1"), newRunningPod("pod2", "c1")
+	client := New(fake.NewSimpleClientset(pod1))
+	f := &podLogFollower{
+		client:    client,
+		namespace: "ns1",
+		handler:   func(line PodLogLine) {},
+		following: map[string]bool{},
+		lastSeen:  map[types.UID]map[string]time.Time{},
+	}
+
+	// pod2 does not exist anymore once its log stream ends, e.g. it was deleted while the pod
+	// watch was being re-established
+	f.followPod(context.Background(), pod1)
+	f.followPod(context.Background(), pod2)
+	f.wg.Wait()
+	require.Contains(t, f.lastSeen, pod1.UID)
+	require.NotContains(t, f.lastSeen, pod2.UID)
+
+	f.forgetPod(pod1.UID)
+	require.Empty(t, f.lastSeen)
+	require.Empty(t, f.following)
+}
+
+func TestParsePodLogLine(t *testing.T) {
+	timestamp, line := parsePodLogLine("2024-01-02T03:04:05.123456789Z hello world")
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC), timestamp)
+	require.Equal(t, "hello world", line)
+
+	timestamp, line = parsePodLogLine("hello world")
+	require.True(t, timestamp.IsZero())
+	require.Equal(t, "hello world", line)
+}
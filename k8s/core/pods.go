@@ -10,13 +10,13 @@ import (
 
 	"github.com/portworx/sched-ops/k8s/common"
 	schederrors "github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
 )
 
 // PodOps is an interface to perform k8s pod operations
@@ -74,12 +74,27 @@ type PodOps interface {
 	RunCommandInPod(cmds []string, podName, containerName, namespace string) (string, error)
 	// RunCommandInPodEx is extended version of RunCommandInPod
 	RunCommandInPodEx(*RunCommandInPodExRequest) error
+	// ExecInPod runs the given command in the given pod and returns its output and exit code
+	ExecInPod(ctx context.Context, req *ExecRequest) (*ExecResult, error)
+	// CopyToPod copies a local file or directory into a container of a pod
+	CopyToPod(ctx context.Context, req *CopyRequest) error
+	// CopyFromPod copies a file or directory from a container of a pod to the local host
+	CopyFromPod(ctx context.Context, req *CopyRequest) error
+	// PortForwardPod forwards local ports to the given pod until ctx is done
+	PortForwardPod(ctx context.Context, namespace, podName string, ports []string) (*PortForward, error)
 	// ValidatePod validates the given pod if it's ready
 	ValidatePod(pod *corev1.Pod, timeout, retryInterval time.Duration) error
 	// WatchPods sets up a watcher that listens for the changes to pods in given namespace
 	WatchPods(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchPodsWithContext watches the pods in given namespace until ctx is done, the watcher is stopped or fn returns an error
+	WatchPodsWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 	// GetPodLogs returns the logs of a POD as a string
 	GetPodLog(podName string, namespace string, podLogOptions *corev1.PodLogOptions) (string, error)
+	// StreamPodLog returns a stream of the logs of a POD. The caller must close the stream.
+	StreamPodLog(ctx context.Context, podName string, namespace string, podLogOptions *corev1.PodLogOptions) (io.ReadCloser, error)
+	// FollowPodLogs follows the logs of every container of every POD matching the label selector in the
+	// given namespace and passes them to the handler until ctx is done
+	FollowPodLogs(ctx context.Context, labelSelector map[string]string, namespace string, handler PodLogHandler) error
 }
 
 // RunCommandInPodExRequest is a request structure for the RunCommandInPodEx func
@@ -206,6 +221,9 @@ func (c *Client) getPodsWithListOptions(namespace string, opts metav1.ListOption
 		return nil, err
 	}
 
+	if pods, ok := c.listCachedPods(namespace, opts, ""); ok {
+		return &corev1.PodList{Items: pods}, nil
+	}
 	return c.kubernetes.CoreV1().Pods(namespace).List(context.TODO(), opts)
 }
 
@@ -227,13 +245,17 @@ func (c *Client) getPodsUsingPVWithListOptions(pvName string, opts metav1.ListOp
 }
 
 func (c *Client) getPodsUsingPVCWithListOptions(pvcName, pvcNamespace string, opts metav1.ListOptions) ([]corev1.Pod, error) {
-	pods, err := c.getPodsWithListOptions(pvcNamespace, opts)
-	if err != nil {
-		return nil, err
+	pods, ok := c.listCachedPods(pvcNamespace, opts, pvcName)
+	if !ok {
+		podList, err := c.getPodsWithListOptions(pvcNamespace, opts)
+		if err != nil {
+			return nil, err
+		}
+		pods = podList.Items
 	}
 
 	retList := make([]corev1.Pod, 0)
-	for _, p := range pods.Items {
+	for _, p := range pods {
 		for _, v := range p.Spec.Volumes {
 			if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == pvcName {
 				// Along PVC present in the volume list, we also checking whether any of the container in the
@@ -279,7 +301,7 @@ func (c *Client) listPluginPodsWithOptions(opts metav1.ListOptions, plugin strin
 		return nil, err
 	}
 
-	nodePods, err := c.kubernetes.CoreV1().Pods("").List(context.TODO(), opts)
+	nodePods, err := c.getPodsWithListOptions("", opts)
 	if err != nil {
 		return nil, err
 	}
@@ -415,7 +437,7 @@ func (c *Client) ValidatePod(pod *corev1.Pod, timeout, retryInterval time.Durati
 
 		return "", false, nil
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("core.ValidatePod")); err != nil {
 		return err
 	}
 	return nil
@@ -423,30 +445,35 @@ func (c *Client) ValidatePod(pod *corev1.Pod, timeout, retryInterval time.Durati
 
 // WatchPods sets up a watcher that listens for the changes to pods in given namespace
 func (c *Client) WatchPods(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchPodsWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchPodsWithContext watches the pods like WatchPods, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchPodsWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.kubernetes.CoreV1().Pods(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*corev1.PodList](c.kubernetes.CoreV1().Pods(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for pods")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(
-		watchInterface,
-		&corev1.Pod{},
-		namespace,
-		fn,
-		listOptions)
-
-	return nil
+	return w, nil
 }
 
 // WaitForPodDeletion waits for given timeout for given pod to be deleted
 func (c *Client) WaitForPodDeletion(uid types.UID, namespace string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.waitForPodDeletion(ctx, uid, namespace)
+}
+
+// waitForPodDeletion waits for the pod with the given uid to be deleted until ctx is done
+func (c *Client) waitForPodDeletion(ctx context.Context, uid types.UID, namespace string) error {
 	t := func() (interface{}, bool, error) {
 		if err := c.initClient(); err != nil {
 			return nil, true, err
@@ -468,7 +495,7 @@ func (c *Client) WaitForPodDeletion(uid types.UID, namespace string, timeout tim
 		return nil, false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+	if _, err := task.DoRetryWithContext(ctx, t, task.ConstantBackoff(5*time.Second), task.WithOperation("core.WaitForPodDeletion")); err != nil {
 		return err
 	}
 
@@ -481,51 +508,33 @@ func (c *Client) RunCommandInPodEx(req *RunCommandInPodExRequest) error {
 		return os.ErrInvalid
 	}
 
-	err := c.initClient()
+	stdout, stderr := req.Stdout, req.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	res, err := c.ExecInPod(context.TODO(), &ExecRequest{
+		Command:       req.Command,
+		PodName:       req.PODName,
+		ContainerName: req.ContainerName,
+		Namespace:     req.Namespace,
+		Stdin:         req.Stdin,
+		Stdout:        stdout,
+		Stderr:        stderr,
+		TTY:           req.UseTTY,
+	})
 	if err != nil {
 		return err
 	}
-
-	if len(req.ContainerName) == 0 {
-		pod, err := c.kubernetes.CoreV1().Pods(req.Namespace).Get(context.TODO(), req.PODName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-
-		if len(pod.Spec.Containers) != 1 {
-			return fmt.Errorf("could not determine which container to use")
+	if res.ExitCode != 0 {
+		return exec.CodeExitError{
+			Err:  fmt.Errorf("command terminated with exit code %d", res.ExitCode),
+			Code: res.ExitCode,
 		}
-
-		req.ContainerName = pod.Spec.Containers[0].Name
 	}
-
-	post := c.kubernetes.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(req.PODName).
-		Namespace(req.Namespace).
-		SubResource("exec")
-
-	post.VersionedParams(&corev1.PodExecOptions{
-		Container: req.ContainerName,
-		Command:   req.Command,
-		Stdin:     (req.Stdin != nil),
-		Stdout:    (req.Stdout != nil),
-		Stderr:    (req.Stderr != nil),
-	}, scheme.ParameterCodec)
-
-	exec, err := remotecommand.NewSPDYExecutor(c.config, "POST", post.URL())
-	if err != nil {
-		return fmt.Errorf("failed to init executor: %v", err)
-	}
-
-	err = exec.Stream(remotecommand.StreamOptions{
-		Stdin:  req.Stdin,
-		Stdout: req.Stdout,
-		Stderr: req.Stderr,
-		Tty:    req.UseTTY,
-	})
-
-	return err
+	return nil
 }
 
 // RunCommandInPod runs given command in the given pod  (simplified syntax)
@@ -548,13 +557,8 @@ func (c *Client) RunCommandInPod(cmds []string, podName, containerName, namespac
 
 // GetPodLog returns the logs of a POD as a string
 func (c *Client) GetPodLog(podName string, ns string, podLogOptions *corev1.PodLogOptions) (string, error) {
-	if err := c.initClient(); err != nil {
-		return "", err
-	}
-
-	l := c.kubernetes.CoreV1().Pods(ns).GetLogs(podName, podLogOptions)
 	buf := new(bytes.Buffer)
-	stream, err := l.Stream(context.TODO())
+	stream, err := c.StreamPodLog(context.TODO(), podName, ns, podLogOptions)
 	if err != nil {
 		return "", err
 	}
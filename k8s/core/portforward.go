@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForward forwards local ports to a pod until the context given to PortForwardPod or
+// PortForwardService is done
+type PortForward struct {
+	// PodName is the name of the pod the ports are forwarded to
+	PodName string
+	// Namespace is the namespace of the pod
+	Namespace string
+
+	forwarder *portforward.PortForwarder
+	ready     chan struct{}
+	done      chan struct{}
+	err       error
+}
+
+// Ready returns a channel which is closed once the local ports are listening
+func (pf *PortForward) Ready() <-chan struct{} {
+	return pf.ready
+}
+
+// Done returns a channel which is closed once the port forwarding stopped, either because the context
+// is done or because the connection to the pod was lost
+func (pf *PortForward) Done() <-chan struct{} {
+	return pf.done
+}
+
+// Err returns the reason the port forwarding stopped once Done is closed
+func (pf *PortForward) Err() error {
+	select {
+	case <-pf.done:
+		return pf.err
+	default:
+		return nil
+	}
+}
+
+// Ports waits for the local ports to be listening and returns them. The local ports are the ones which
+// were bound if 0 was requested.
+func (pf *PortForward) Ports() ([]portforward.ForwardedPort, error) {
+	select {
+	case <-pf.ready:
+		return pf.forwarder.GetPorts()
+	case <-pf.done:
+		if pf.err != nil {
+			return nil, pf.err
+		}
+		return nil, fmt.Errorf("port forwarding to pod %s/%s stopped", pf.Namespace, pf.PodName)
+	}
+}
+
+// PortForwardPod forwards local ports to the given pod until ctx is done. The ports use the kubectl
+// port-forward format: "8080" forwards the local port 8080 to the port 8080 of the pod, "9090:8080"
+// forwards the local port 9090 and ":8080" forwards a random local port.
+func (c *Client) PortForwardPod(ctx context.Context, namespace, podName string, ports []string) (*PortForward, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("at least one port must be given to forward to pod %s/%s", namespace, podName)
+	}
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+	if c.config == nil {
+		return nil, fmt.Errorf("port forwarding requires a client created from a rest config")
+	}
+
+	pod, err := c.kubernetes.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, fmt.Errorf("unable to forward ports to pod %s/%s: pod is %s", namespace, podName, pod.Status.Phase)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init port forwarding: %v", err)
+	}
+	url := c.kubernetes.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	pf := &PortForward{
+		PodName:   podName,
+		Namespace: namespace,
+		ready:     make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	stop := make(chan struct{})
+	pf.forwarder, err = portforward.New(dialer, ports, stop, pf.ready, io.Discard, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-pf.done:
+		}
+		close(stop)
+	}()
+	go func() {
+		defer close(pf.done)
+		pf.err = pf.forwarder.ForwardPorts()
+		if pf.err == nil {
+			pf.err = ctx.Err()
+		}
+	}()
+	return pf, nil
+}
+
+// PortForwardService forwards a random local port to the given port of the service until ctx is done.
+// The ports are forwarded to one of the ready pods backing the service.
+func (c *Client) PortForwardService(ctx context.Context, namespace, serviceName string, port int32) (*PortForward, error) {
+	podName, targetPort, err := c.resolveServicePort(ctx, namespace, serviceName, port)
+	if err != nil {
+		return nil, err
+	}
+	return c.PortForwardPod(ctx, namespace, podName, []string{fmt.Sprintf(":%d", targetPort)})
+}
+
+// resolveServicePort returns a ready pod backing the given port of the service, and the port of the
+// pod it maps to.
+func (c *Client) resolveServicePort(ctx context.Context, namespace, serviceName string, port int32) (string, int32, error) {
+	if err := c.initClient(); err != nil {
+		return "", 0, err
+	}
+
+	svc, err := c.kubernetes.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	var servicePort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		if svc.Spec.Ports[i].Port == port {
+			servicePort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if servicePort == nil {
+		return "", 0, fmt.Errorf("service %s/%s does not have port %d", namespace, serviceName, port)
+	}
+
+	endpoints, err := c.kubernetes.CoreV1().Endpoints(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, endpointPort := range subset.Ports {
+			// the endpoint ports have the names of the service ports, a single unnamed port is allowed
+			if endpointPort.Name != servicePort.Name {
+				continue
+			}
+			for _, address := range subset.Addresses {
+				if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+					return address.TargetRef.Name, endpointPort.Port, nil
+				}
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("no ready pod found for port %d of service %s/%s", port, namespace, serviceName)
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveServicePort(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc1", Namespace: "ns1"},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromString("web")},
+				{Name: "metrics", Port: 9000, TargetPort: intstr.FromInt(9090)},
+			},
+		},
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc1", Namespace: "ns1"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				NotReadyAddresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "not-ready"}},
+				},
+				Ports: []corev1.EndpointPort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}},
+			},
+			{
+				Addresses: []corev1.EndpointAddress{
+					{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod1"}},
+				},
+				Ports: []corev1.EndpointPort{{Name: "http", Port: 8081}, {Name: "metrics", Port: 9090}},
+			},
+		},
+	}
+	client := New(fake.NewSimpleClientset(svc, endpoints))
+
+	pod, port, err := client.resolveServicePort(context.Background(), "ns1", "svc1", 80)
+	require.NoError(t, err)
+	require.Equal(t, "pod1", pod)
+	require.Equal(t, int32(8081), port)
+
+	pod, port, err = client.resolveServicePort(context.Background(), "ns1", "svc1", 9000)
+	require.NoError(t, err)
+	require.Equal(t, "pod1", pod)
+	require.Equal(t, int32(9090), port)
+
+	_, _, err = client.resolveServicePort(context.Background(), "ns1", "svc1", 443)
+	require.EqualError(t, err, "service ns1/svc1 does not have port 443")
+
+	endpoints.Subsets = endpoints.Subsets[:1]
+	_, err = client.kubernetes.CoreV1().Endpoints("ns1").Update(context.TODO(), endpoints, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, _, err = client.resolveServicePort(context.Background(), "ns1", "svc1", 80)
+	require.EqualError(t, err, "no ready pod found for port 80 of service ns1/svc1")
+}
+
+func TestPortForwardPodValidation(t *testing.T) {
+	client := New(fake.NewSimpleClientset(newRunningPod("pod1", "c1")))
+
+	_, err := client.PortForwardPod(context.Background(), "ns1", "pod1", nil)
+	require.Error(t, err)
+	_, err = client.PortForwardPod(context.Background(), "ns1", "pod1", []string{"8080"})
+	require.EqualError(t, err, "port forwarding requires a client created from a rest config")
+}
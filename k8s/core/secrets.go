@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,6 +25,8 @@ type SecretOps interface {
 	DeleteSecret(name, namespace string) error
 	// WatchSecret changes and callback fn
 	WatchSecret(*corev1.Secret, WatchFunc) error
+	// WatchSecretWithContext watches the secret until ctx is done, the watcher is stopped or fn returns an error
+	WatchSecretWithContext(context.Context, *corev1.Secret, schedwatch.EventFunc) (*schedwatch.Watcher, error)
 	// ListSecret list secret using filters or list all if options are empty
 	ListSecret(string, metav1.ListOptions) (*corev1.SecretList, error)
 }
@@ -105,21 +108,20 @@ func (c *Client) DeleteSecret(name, namespace string) error {
 
 // WatchSecret changes and callback fn
 func (c *Client) WatchSecret(secret *v1.Secret, fn WatchFunc) error {
+	return schedwatch.InBackground(c.WatchSecretWithContext(context.Background(), secret, schedwatch.ObjectFunc(fn)))
+}
+
+// WatchSecretWithContext watches the secret like WatchSecret, calling fn with the type and object of each change until
+// ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchSecretWithContext(ctx context.Context, secret *v1.Secret, fn schedwatch.EventFunc) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
 	listOptions := metav1.ListOptions{
 		FieldSelector: fields.OneTermEqualSelector("metadata.name", secret.Name).String(),
-		Watch:         true,
-	}
-
-	watchInterface, err := c.kubernetes.CoreV1().Secrets(secret.Namespace).Watch(context.TODO(), listOptions)
-	if err != nil {
-		return err
 	}
 
-	// fire off watch function
-	go c.handleWatch(watchInterface, secret, "", fn, listOptions)
-	return nil
+	source := schedwatch.ForClient[*v1.SecretList](c.kubernetes.CoreV1().Secrets(secret.Namespace))
+	return schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 }
@@ -30,6 +30,8 @@ type ServiceOps interface {
 	PatchService(name, namespace string, jsonPatch []byte, subresources ...string) (*corev1.Service, error)
 	// UpdateService updates the given service
 	UpdateService(*corev1.Service) (*corev1.Service, error)
+	// PortForwardService forwards a local port to a ready pod backing the given port of the service until ctx is done
+	PortForwardService(ctx context.Context, namespace, serviceName string, port int32) (*PortForward, error)
 }
 
 // CreateService creates the given service
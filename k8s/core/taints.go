@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/portworx/sched-ops/task"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// taintPollInterval is the time between the checks of WaitForPodsToTolerateTaint
+const taintPollInterval = time.Second
+
+// GetTaints returns the taints of the given node
+func (c *Client) GetTaints(nodeName string) ([]corev1.Taint, error) {
+	node, err := c.GetNodeByName(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return node.Spec.Taints, nil
+}
+
+// AddTaintOnNode adds the taint on the given node. A taint with the same key and effect is replaced.
+func (c *Client) AddTaintOnNode(nodeName string, taint corev1.Taint) error {
+	if taint.Key == "" || taint.Effect == "" {
+		return fmt.Errorf("taint key and effect are required")
+	}
+	if taint.Effect == corev1.TaintEffectNoExecute && taint.TimeAdded == nil {
+		// the taint manager uses the time to evict the pods with a toleration period
+		now := metav1.Now()
+		taint.TimeAdded = &now
+	}
+
+	return c.updateTaints(nodeName, func(taints []corev1.Taint) ([]corev1.Taint, bool) {
+		updated := make([]corev1.Taint, 0, len(taints)+1)
+		for _, t := range taints {
+			if t.MatchTaint(&taint) {
+				if t.Value == taint.Value {
+					return taints, false
+				}
+				continue
+			}
+			updated = append(updated, t)
+		}
+		return append(updated, taint), true
+	})
+}
+
+// RemoveTaintOnNode removes the taint with the given key and effect from the given node. All the taints
+// with the key are removed if the effect is empty.
+func (c *Client) RemoveTaintOnNode(nodeName, key string, effect corev1.TaintEffect) error {
+	return c.updateTaints(nodeName, func(taints []corev1.Taint) ([]corev1.Taint, bool) {
+		updated := make([]corev1.Taint, 0, len(taints))
+		for _, t := range taints {
+			if t.Key == key && (effect == "" || t.Effect == effect) {
+				continue
+			}
+			updated = append(updated, t)
+		}
+		return updated, len(updated) != len(taints)
+	})
+}
+
+// updateTaints patches the taints of the node with the ones returned by update, if it reports a
+// change. The patch fails with a conflict if the node changed since it was read, in which case it is
+// retried with the new taints.
+func (c *Client) updateTaints(nodeName string, update func([]corev1.Taint) ([]corev1.Taint, bool)) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	var err error
+	for retryCnt := 0; retryCnt < labelUpdateMaxRetries; retryCnt++ {
+		var node *corev1.Node
+		node, err = c.kubernetes.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		taints, changed := update(node.Spec.Taints)
+		if !changed {
+			return nil
+		}
+
+		// the taints are not merged by a strategic merge patch, the list is replaced as a whole
+		var patch []byte
+		patch, err = json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": node.ResourceVersion,
+			},
+			"spec": map[string]interface{}{
+				"taints": taints,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.kubernetes.CoreV1().Nodes().Patch(context.TODO(), nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+		if err == nil || !apierrors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// WaitForPodsToTolerateTaint waits until all the pods running on the given node tolerate the taint or
+// ctx is done. With a NoExecute taint, this waits for the pods which do not tolerate it to be evicted.
+func (c *Client) WaitForPodsToTolerateTaint(ctx context.Context, nodeName string, taint corev1.Taint) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	t := func() (interface{}, bool, error) {
+		pods, err := c.kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+		})
+		if err != nil {
+			return nil, true, err
+		}
+
+		var intolerant []string
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			if !PodToleratesTaint(&pod, &taint) {
+				intolerant = append(intolerant, pod.Namespace+"/"+pod.Name)
+			}
+		}
+		if len(intolerant) > 0 {
+			return nil, true, fmt.Errorf("pods %v on node %s do not tolerate taint %s", intolerant, nodeName, taint.ToString())
+		}
+		return nil, false, nil
+	}
+
+	_, err := task.DoRetryWithContext(ctx, t, task.ConstantBackoff(taintPollInterval), task.WithOperation("core.WaitForPodsToTolerateTaint"))
+	return err
+}
+
+// PodToleratesTaint returns true if one of the tolerations of the pod tolerates the taint
+func PodToleratesTaint(pod *corev1.Pod, taint *corev1.Taint) bool {
+	for i := range pod.Spec.Tolerations {
+		if pod.Spec.Tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNodeTaints(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	conflicts := 1
+	clientset.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			conflicts--
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "nodes"}, "node1", nil)
+		}
+		return false, nil, nil
+	})
+	client := New(clientset)
+
+	maintenance := corev1.Taint{Key: "maintenance", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	require.NoError(t, client.AddTaintOnNode("node1", maintenance))
+	require.Zero(t, conflicts, "Expected the conflicting patch to be retried")
+	taints, err := client.GetTaints("node1")
+	require.NoError(t, err)
+	require.Equal(t, []corev1.Taint{maintenance}, taints)
+
+	// same key and effect replaces the value, another effect is a different taint
+	maintenance.Value = "false"
+	require.NoError(t, client.AddTaintOnNode("node1", maintenance))
+	require.NoError(t, client.AddTaintOnNode("node1", corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}))
+	require.NoError(t, client.AddTaintOnNode("node1", corev1.Taint{Key: "other", Effect: corev1.TaintEffectNoSchedule}))
+	taints, err = client.GetTaints("node1")
+	require.NoError(t, err)
+	require.Len(t, taints, 3)
+	require.Equal(t, maintenance, taints[0])
+	require.NotNil(t, taints[1].TimeAdded, "Expected NoExecute taints to have the time they were added")
+
+	require.NoError(t, client.RemoveTaintOnNode("node1", "maintenance", corev1.TaintEffectNoExecute))
+	taints, err = client.GetTaints("node1")
+	require.NoError(t, err)
+	require.Len(t, taints, 2)
+	require.NoError(t, client.RemoveTaintOnNode("node1", "maintenance", ""))
+	require.NoError(t, client.RemoveTaintOnNode("node1", "missing", ""))
+	taints, err = client.GetTaints("node1")
+	require.NoError(t, err)
+	require.Equal(t, []corev1.Taint{{Key: "other", Effect: corev1.TaintEffectNoSchedule}}, taints)
+
+	require.Error(t, client.AddTaintOnNode("node1", corev1.Taint{Key: "no-effect"}))
+}
+
+func TestWaitForPodsToTolerateTaint(t *testing.T) {
+	taint := corev1.Taint{Key: "maintenance", Effect: corev1.TaintEffectNoExecute}
+	tolerant := newDrainPod("tolerant")
+	tolerant.Spec.Tolerations = []corev1.Toleration{{Key: "maintenance", Operator: corev1.TolerationOpExists}}
+	completed := newDrainPod("completed")
+	completed.Status.Phase = corev1.PodSucceeded
+	client := New(fake.NewSimpleClientset(tolerant, completed, newDrainPod("intolerant")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.Error(t, client.WaitForPodsToTolerateTaint(ctx, "node1", taint))
+
+	require.NoError(t, client.kubernetes.CoreV1().Pods("ns1").Delete(context.TODO(), "intolerant", metav1.DeleteOptions{}))
+	require.NoError(t, client.WaitForPodsToTolerateTaint(context.Background(), "node1", taint))
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/portworx/sched-ops/k8s/common"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -13,8 +13,13 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -23,6 +28,9 @@ var (
 	once     sync.Once
 )
 
+// mapperResetInterval is the minimum time between two discoveries of the API server for unknown kinds
+const mapperResetInterval = 30 * time.Second
+
 // Ops is an interface to perform generic Object operations
 type Ops interface {
 	// GetObject returns the latest object given a generic Object
@@ -31,6 +39,16 @@ type Ops interface {
 	UpdateObject(object runtime.Object) (runtime.Object, error)
 	// ListObjects returns a list of generic Objects using the options
 	ListObjects(options *metav1.ListOptions, namespace string) (*unstructured.UnstructuredList, error)
+	// CreateObject creates a generic Object
+	CreateObject(ctx context.Context, object runtime.Object) (runtime.Object, error)
+	// DeleteObject deletes a generic Object with the given propagation policy, or the default one of its resource if empty
+	DeleteObject(ctx context.Context, object runtime.Object, propagationPolicy metav1.DeletionPropagation) error
+	// PatchObject patches a generic Object with a JSON, merge or strategic merge patch
+	PatchObject(ctx context.Context, object runtime.Object, patchType types.PatchType, data []byte) (runtime.Object, error)
+	// ApplyObject applies a generic Object using server-side apply as the given field manager
+	ApplyObject(ctx context.Context, object runtime.Object, fieldManager string, force bool) (runtime.Object, error)
+	// GetResourceClient returns the client of the resource of a generic Object, in its namespace if it has one
+	GetResourceClient(object runtime.Object) (dynamic.ResourceInterface, error)
 
 	// SetConfig sets the config and resets the client
 	SetConfig(config *rest.Config)
@@ -52,22 +70,29 @@ func SetInstance(i Ops) {
 	instance = i
 }
 
-// New builds a new client.
+// New builds a new client. Without the discovery of the API server, the resources of the objects are
+// guessed from their kinds, see NewForConfig.
 func New(client dynamic.Interface) *Client {
 	return &Client{
 		client: client,
 	}
 }
 
-// NewForConfig builds a new client for the given config.
+// NewForConfig builds a new client for the given config. The resources of the objects are resolved
+// using the discovery of the API server.
 func NewForConfig(c *rest.Config) (*Client, error) {
 	client, err := dynamic.NewForConfig(c)
 	if err != nil {
 		return nil, err
 	}
+	mapper, err := newRESTMapper(c)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Client{
 		client: client,
+		mapper: mapper,
 	}, nil
 }
 
@@ -86,12 +111,20 @@ func NewInstanceFromConfigFile(config string) (Ops, error) {
 type Client struct {
 	config *rest.Config
 	client dynamic.Interface
+
+	// mapperLock protects mapper and mapperResetTime
+	mapperLock sync.Mutex
+	// mapper resolves the resources of the kinds, nil if the discovery is not available
+	mapper meta.RESTMapper
+	// mapperResetTime is the last time the mapper was reset to discover a kind it did not know
+	mapperResetTime time.Time
 }
 
 // SetConfig sets the config and resets the client
 func (c *Client) SetConfig(cfg *rest.Config) {
 	c.config = cfg
 	c.client = nil
+	c.setMapper(nil)
 }
 
 // GetObject returns the latest object given a generic Object
@@ -139,9 +172,13 @@ func (c *Client) ListObjects(options *metav1.ListOptions, namespace string) (*un
 
 	var client dynamic.ResourceInterface
 	gvk := schema.FromAPIVersionAndKind(options.APIVersion, options.Kind)
-	resourceInterface := c.client.Resource(gvk.GroupVersion().WithResource(strings.ToLower(gvk.Kind) + "s"))
+	mapping, err := c.getRESTMapping(gvk)
+	if err != nil {
+		return nil, err
+	}
+	resourceInterface := c.client.Resource(mapping.Resource)
 
-	if namespace != "" {
+	if namespace != "" && !isClusterScoped(mapping) {
 		client = resourceInterface.Namespace(namespace)
 	} else {
 		client = resourceInterface
@@ -150,6 +187,110 @@ func (c *Client) ListObjects(options *metav1.ListOptions, namespace string) (*un
 	return client.List(context.TODO(), *options)
 }
 
+// CreateObject creates a generic Object
+func (c *Client) CreateObject(ctx context.Context, object runtime.Object) (runtime.Object, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.getDynamicClient(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Create(ctx, u, metav1.CreateOptions{})
+}
+
+// DeleteObject deletes a generic Object with the given propagation policy, or the default one of its
+// resource if empty
+func (c *Client) DeleteObject(ctx context.Context, object runtime.Object, propagationPolicy metav1.DeletionPropagation) error {
+	if err := c.initClient(); err != nil {
+		return err
+	}
+
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return err
+	}
+	client, err := c.getDynamicClient(u)
+	if err != nil {
+		return err
+	}
+
+	options := metav1.DeleteOptions{}
+	if propagationPolicy != "" {
+		options.PropagationPolicy = &propagationPolicy
+	}
+	return client.Delete(ctx, u.GetName(), options)
+}
+
+// PatchObject patches a generic Object with a JSON, merge or strategic merge patch. Custom resources
+// do not support strategic merge patches.
+func (c *Client) PatchObject(ctx context.Context, object runtime.Object, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	switch patchType {
+	case types.JSONPatchType, types.MergePatchType, types.StrategicMergePatchType:
+	default:
+		return nil, fmt.Errorf("unsupported patch type: %v, ApplyObject must be used for server-side apply", patchType)
+	}
+
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.getDynamicClient(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Patch(ctx, u.GetName(), patchType, data, metav1.PatchOptions{})
+}
+
+// ApplyObject applies a generic Object using server-side apply as the given field manager. The object
+// holds the fields owned by the field manager. If force is true, the fields owned by other managers
+// are taken over instead of failing with a conflict.
+func (c *Client) ApplyObject(ctx context.Context, object runtime.Object, fieldManager string, force bool) (runtime.Object, error) {
+	if fieldManager == "" {
+		return nil, fmt.Errorf("field manager is required to apply an object")
+	}
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	u, err := ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.getDynamicClient(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// the managed fields cannot be applied
+	u = u.DeepCopy()
+	u.SetManagedFields(nil)
+	return client.Apply(ctx, u.GetName(), u, metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        force,
+	})
+}
+
+// GetResourceClient returns the client of the resource of a generic Object, in its namespace if it has one
+func (c *Client) GetResourceClient(object runtime.Object) (dynamic.ResourceInterface, error) {
+	if err := c.initClient(); err != nil {
+		return nil, err
+	}
+
+	return c.getDynamicClient(object)
+}
+
 func (c *Client) getDynamicClient(object runtime.Object) (dynamic.ResourceInterface, error) {
 
 	objectType, err := meta.TypeAccessor(object)
@@ -162,13 +303,102 @@ func (c *Client) getDynamicClient(object runtime.Object) (dynamic.ResourceInterf
 		return nil, err
 	}
 
-	resourceInterface := c.client.Resource(object.GetObjectKind().GroupVersionKind().GroupVersion().WithResource(strings.ToLower(objectType.GetKind()) + "s"))
-	if metadata.GetNamespace() == "" {
+	mapping, err := c.getRESTMapping(schema.FromAPIVersionAndKind(objectType.GetAPIVersion(), objectType.GetKind()))
+	if err != nil {
+		return nil, err
+	}
+
+	resourceInterface := c.client.Resource(mapping.Resource)
+	if metadata.GetNamespace() == "" || isClusterScoped(mapping) {
 		return resourceInterface, nil
 	}
 	return resourceInterface.Namespace(metadata.GetNamespace()), nil
 }
 
+// getRESTMapping returns the mapping of the kind to its resource. The API server is discovered again
+// if the kind is unknown, in case its custom resource definition was installed since the last discovery,
+// at most once every mapperResetInterval. Without a mapper, the resource is guessed from the kind and
+// its scope is unknown.
+func (c *Client) getRESTMapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapper := c.getMapper()
+	if mapper == nil {
+		resource, _ := meta.UnsafeGuessKindToResource(gvk)
+		return &meta.RESTMapping{Resource: resource, GroupVersionKind: gvk}, nil
+	}
+
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	// The deferred mapper only discovers again by itself while its cache is not fresh, and the memory
+	// cache stays fresh once filled, so new kinds are only found by resetting it.
+	if meta.IsNoMatchError(err) && c.allowMapperReset() {
+		if resettable, ok := mapper.(meta.ResettableRESTMapper); ok {
+			resettable.Reset()
+			mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	return mapping, err
+}
+
+func (c *Client) getMapper() meta.RESTMapper {
+	c.mapperLock.Lock()
+	defer c.mapperLock.Unlock()
+	return c.mapper
+}
+
+func (c *Client) setMapper(mapper meta.RESTMapper) {
+	c.mapperLock.Lock()
+	defer c.mapperLock.Unlock()
+	c.mapper = mapper
+	c.mapperResetTime = time.Time{}
+}
+
+// allowMapperReset returns true if the mapper was not reset during the last mapperResetInterval, and
+// records the reset, so that unknown kinds looked up repeatedly do not flood the discovery API.
+func (c *Client) allowMapperReset() bool {
+	c.mapperLock.Lock()
+	defer c.mapperLock.Unlock()
+	if time.Since(c.mapperResetTime) < mapperResetInterval {
+		return false
+	}
+	c.mapperResetTime = time.Now()
+	return true
+}
+
+func isClusterScoped(mapping *meta.RESTMapping) bool {
+	return mapping.Scope != nil && mapping.Scope.Name() == meta.RESTScopeNameRoot
+}
+
+// newRESTMapper returns a mapper using the discovery of the API server, which is cached until a kind
+// cannot be found
+func newRESTMapper(config *rest.Config) (meta.RESTMapper, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)), nil
+}
+
+// ToUnstructured converts a typed object to an unstructured one, with its kind set from the scheme
+// if the object lacks it. Unstructured objects are returned as is.
+func ToUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := object.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if u.GetKind() == "" {
+		gvks, _, err := scheme.Scheme.ObjectKinds(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the kind of %T, it must be set on the object: %v", object, err)
+		}
+		u.SetGroupVersionKind(gvks[0])
+	}
+	return u, nil
+}
+
 // initClient the k8s client if uninitialized
 func (c *Client) initClient() error {
 	if c.client != nil {
@@ -232,6 +462,11 @@ func (c *Client) loadClient() error {
 	if err != nil {
 		return err
 	}
+	mapper, err := newRESTMapper(c.config)
+	if err != nil {
+		return err
+	}
+	c.setMapper(mapper)
 
 	return nil
 }
@@ -1,9 +1,23 @@
 package dynamic
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestInstance(t *testing.T) {
@@ -11,3 +25,131 @@ func TestInstance(t *testing.T) {
 
 	require.NotNil(t, instance, "instance should be initialized")
 }
+
+func TestObjectOperations(t *testing.T) {
+	fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+	client := New(fakeClient)
+
+	// typed objects without their kind are supported
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "ns1"},
+		Data:       map[string]string{"key": "value"},
+	}
+	created, err := client.CreateObject(context.TODO(), configMap)
+	require.NoError(t, err)
+	require.Equal(t, "ConfigMap", created.GetObjectKind().GroupVersionKind().Kind)
+
+	patched, err := client.PatchObject(context.TODO(), configMap, types.MergePatchType, []byte(`{"data":{"key":"patched"}}`))
+	require.NoError(t, err)
+	value, _, err := unstructured.NestedString(patched.(*unstructured.Unstructured).Object, "data", "key")
+	require.NoError(t, err)
+	require.Equal(t, "patched", value)
+	_, err = client.PatchObject(context.TODO(), configMap, types.ApplyPatchType, []byte(`{}`))
+	require.Error(t, err)
+
+	require.NoError(t, client.DeleteObject(context.TODO(), configMap, metav1.DeletePropagationBackground))
+	_, err = client.GetObject(created)
+	require.Error(t, err, "Expected the object to be deleted")
+}
+
+func TestApplyObject(t *testing.T) {
+	fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme)
+	var applied *unstructured.Unstructured
+	fakeClient.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		require.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+		applied = &unstructured.Unstructured{}
+		require.NoError(t, applied.UnmarshalJSON(patch.GetPatch()))
+		return true, applied, nil
+	})
+	client := New(fakeClient)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "cm1",
+			Namespace:     "ns1",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "other"}},
+		},
+		Data: map[string]string{"key": "value"},
+	}
+	_, err := client.ApplyObject(context.TODO(), configMap, "", false)
+	require.Error(t, err, "Expected the field manager to be required")
+
+	_, err = client.ApplyObject(context.TODO(), configMap, "sched-ops", true)
+	require.NoError(t, err)
+	require.NotNil(t, applied)
+	require.Equal(t, "v1", applied.GetAPIVersion())
+	require.Equal(t, "ConfigMap", applied.GetKind())
+	require.Empty(t, applied.GetManagedFields())
+	require.Len(t, configMap.ManagedFields, 1, "Expected the given object to be left unchanged")
+}
+
+func TestRESTMapping(t *testing.T) {
+	networkPolicy := &unstructured.Unstructured{}
+	networkPolicy.SetAPIVersion("networking.k8s.io/v1")
+	networkPolicy.SetKind("NetworkPolicy")
+	networkPolicy.SetName("np1")
+	networkPolicy.SetNamespace("ns1")
+	storageClass := &unstructured.Unstructured{}
+	storageClass.SetAPIVersion("storage.k8s.io/v1")
+	storageClass.SetKind("StorageClass")
+	storageClass.SetName("sc1")
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetName("widget1")
+	widget.SetNamespace("ns1")
+
+	widgets := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	fakeClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			widgets: "WidgetList",
+			{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}: "NetworkPolicyList",
+			{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}:     "StorageClassList",
+		}, networkPolicy, storageClass, widget)
+	fakeDiscovery := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	fakeDiscovery.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true}},
+		},
+		{
+			GroupVersion: "storage.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "storageclasses", Kind: "StorageClass"}},
+		},
+	}
+	client := New(fakeClient)
+	client.mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(fakeDiscovery))
+
+	_, err := client.GetObject(networkPolicy)
+	require.NoError(t, err)
+	// a namespace set on a cluster scoped object is ignored
+	sc := storageClass.DeepCopy()
+	sc.SetNamespace("ns1")
+	_, err = client.GetObject(sc)
+	require.NoError(t, err)
+	list, err := client.ListObjects(&metav1.ListOptions{TypeMeta: metav1.TypeMeta{APIVersion: "storage.k8s.io/v1", Kind: "StorageClass"}}, "ns1")
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+
+	// a newly installed custom resource is discovered
+	_, err = client.GetObject(widget)
+	require.Error(t, err)
+	fakeDiscovery.Resources = append(fakeDiscovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+	})
+	// the discovery is throttled
+	discoveries := len(fakeDiscovery.Actions())
+	_, err = client.GetObject(widget)
+	require.Error(t, err)
+	require.Len(t, fakeDiscovery.Actions(), discoveries, "Expected no discovery within the reset interval")
+	client.mapperResetTime = time.Now().Add(-mapperResetInterval)
+	_, err = client.GetObject(widget)
+	require.NoError(t, err)
+
+	// without discovery the resource is guessed from the kind
+	client = New(fakeClient)
+	_, err = client.GetObject(networkPolicy)
+	require.NoError(t, err)
+}
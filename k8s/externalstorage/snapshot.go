@@ -115,7 +115,7 @@ func (c *Client) ValidateSnapshot(name string, namespace string, retry bool, tim
 	}
 
 	if retry {
-		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("externalstorage.ValidateSnapshot")); err != nil {
 			return err
 		}
 	} else {
@@ -159,7 +159,7 @@ func (c *Client) ValidateSnapshotData(name string, retry bool, timeout, retryInt
 	}
 
 	if retry {
-		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("externalstorage.ValidateSnapshotData")); err != nil {
 			return err
 		}
 	} else {
@@ -103,7 +103,7 @@ func (c *Client) ValidateDataExport(name string, namespace string, timeout, retr
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("kdmp.ValidateDataExport")); err != nil {
 		return err
 	}
 
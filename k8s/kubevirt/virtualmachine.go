@@ -169,7 +169,7 @@ func (c *Client) ValidateVirtualMachineRunning(name, namespace string, timeout,
 			vm.Status.PrintableStatus, vm.Status.Conditions)
 
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("kubevirt.ValidateVirtualMachineRunning")); err != nil {
 		return err
 	}
 	return nil
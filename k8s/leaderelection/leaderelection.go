@@ -0,0 +1,191 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/portworx/sched-ops/k8s/core/configmap"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultKey is the configmap lock key used for the election unless WithKey is given
+	DefaultKey = "leader"
+	// DefaultRetryPeriod is the time to wait before campaigning again after failing to become
+	// the leader or after losing the leadership
+	DefaultRetryPeriod = 5 * time.Second
+)
+
+// LockLostCallback is called with the reason when the leader loses the lock without being
+// asked to stop, e.g. because the lock could not be refreshed in time. The leadership ends
+// once the lock could not be refreshed for two thirds of the lock TTL, before another
+// candidate can take the expired lock.
+type LockLostCallback func(identity string, err error)
+
+// LeaderElector elects a leader among the processes using the same configmap lock.
+type LeaderElector struct {
+	cm          configmap.ConfigMap
+	key         string
+	retryPeriod time.Duration
+	onLockLost  LockLostCallback
+	cmOptions   []configmap.Option
+
+	mu      sync.Mutex
+	leading bool
+}
+
+// Option customizes the LeaderElector returned by New.
+type Option func(*LeaderElector)
+
+// WithKey sets the configmap lock key used for the election. It defaults to DefaultKey.
+func WithKey(key string) Option {
+	return func(le *LeaderElector) {
+		le.key = key
+	}
+}
+
+// WithRetryPeriod sets the time to wait before campaigning again. It defaults to DefaultRetryPeriod.
+func WithRetryPeriod(retryPeriod time.Duration) Option {
+	return func(le *LeaderElector) {
+		le.retryPeriod = retryPeriod
+	}
+}
+
+// WithLockLostCallback sets the callback invoked when the leader loses the lock.
+func WithLockLostCallback(cb LockLostCallback) Option {
+	return func(le *LeaderElector) {
+		le.onLockLost = cb
+	}
+}
+
+// WithConfigMapOptions sets the options of the configmap holding the lock, e.g. its namespace,
+// the core client or configmap.WithLockBackend to elect the leader with a Lease object.
+func WithConfigMapOptions(opts ...configmap.Option) Option {
+	return func(le *LeaderElector) {
+		le.cmOptions = append(le.cmOptions, opts...)
+	}
+}
+
+// New returns a LeaderElector using a lock in the configmap with the given name. The configmap is
+// created if it does not exist.
+func New(name string, opts ...Option) (*LeaderElector, error) {
+	le := &LeaderElector{
+		key:         DefaultKey,
+		retryPeriod: DefaultRetryPeriod,
+	}
+	for _, opt := range opts {
+		opt(le)
+	}
+	if le.key == "" {
+		return nil, errors.New("leader election key cannot be empty")
+	}
+
+	// The leader holds the lock for as long as it runs, so the lock hold timeout is disabled
+	cmOptions := append(append([]configmap.Option{}, le.cmOptions...), configmap.WithLockHoldTimeout(0))
+	cm, err := configmap.NewWithOptions(name, nil, cmOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leader election configmap %s: %w", name, err)
+	}
+	le.cm = cm
+	return le, nil
+}
+
+// Run campaigns for the leadership with the given identity until ctx is done. Every time it becomes
+// the leader, onStartedLeading is called in a new goroutine with a context that is cancelled when the
+// leadership ends. Run then waits for onStartedLeading to return before calling onStoppedLeading, so
+// onStartedLeading must return soon after its context is done. If the lock was lost, the lock lost
+// callback is invoked and Run campaigns again after the retry period. Run releases the lock and returns
+// the ctx error once ctx is done.
+func (le *LeaderElector) Run(
+	ctx context.Context,
+	identity string,
+	onStartedLeading func(ctx context.Context),
+	onStoppedLeading func(),
+) error {
+	if identity == "" {
+		return errors.New("leader election identity cannot be empty")
+	}
+
+	for {
+		lease, err := le.cm.LockWithKeyCtx(ctx, identity, le.key)
+		if err == nil {
+			le.lead(ctx, identity, lease, onStartedLeading, onStoppedLeading)
+		} else if ctx.Err() == nil {
+			logrus.WithError(err).Debugf("%s failed to become the leader for %s", identity, le.key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(le.retryPeriod):
+		}
+	}
+}
+
+// lead runs the leader callbacks for the given lease until it ends.
+func (le *LeaderElector) lead(
+	ctx context.Context,
+	identity string,
+	lease configmap.Lease,
+	onStartedLeading func(ctx context.Context),
+	onStoppedLeading func(),
+) {
+	logrus.Infof("%s became the leader for %s", identity, le.key)
+	le.setLeading(true)
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		if onStartedLeading != nil {
+			onStartedLeading(lease.Context())
+		}
+	}()
+
+	<-lease.Done()
+	if err := lease.Unlock(); err != nil {
+		logrus.WithError(err).Warnf("%s failed to release the leader lock for %s", identity, le.key)
+	}
+	le.setLeading(false)
+
+	if ctx.Err() == nil {
+		err := lease.Err()
+		logrus.WithError(err).Warnf("%s lost the leadership for %s", identity, le.key)
+		if le.onLockLost != nil {
+			le.onLockLost(identity, err)
+		}
+	}
+
+	<-leaderDone
+	if onStoppedLeading != nil {
+		onStoppedLeading()
+	}
+	logrus.Infof("%s stopped leading for %s", identity, le.key)
+}
+
+// GetLeader returns the identity of the current leader, or an empty string if there is none.
+func (le *LeaderElector) GetLeader() (string, error) {
+	locked, owner, err := le.cm.IsKeyLocked(le.key, "")
+	if err != nil {
+		return "", err
+	}
+	if !locked {
+		return "", nil
+	}
+	return owner, nil
+}
+
+// IsLeader returns true if Run in this process is currently the leader.
+func (le *LeaderElector) IsLeader() bool {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	return le.leading
+}
+
+func (le *LeaderElector) setLeading(leading bool) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	le.leading = leading
+}
@@ -0,0 +1,184 @@
+package leaderelection
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/portworx/sched-ops/k8s/core"
+	"github.com/portworx/sched-ops/k8s/core/configmap"
+	"github.com/stretchr/testify/require"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testName    = "px-leader-election-test"
+	testTimeout = 10 * time.Second
+)
+
+func newTestElector(t *testing.T, ops core.Ops, opts ...Option) *LeaderElector {
+	opts = append([]Option{
+		WithRetryPeriod(100 * time.Millisecond),
+		WithConfigMapOptions(
+			configmap.WithCoreOps(ops),
+			configmap.WithLockAttempts(1),
+			configmap.WithLockRefreshDuration(time.Second),
+			configmap.WithLockTTL(3*time.Second),
+		),
+	}, opts...)
+	le, err := New(testName, opts...)
+	require.NoError(t, err)
+	return le
+}
+
+type testCallbacks struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newTestCallbacks() *testCallbacks {
+	return &testCallbacks{
+		started: make(chan struct{}, 10),
+		stopped: make(chan struct{}, 10),
+	}
+}
+
+func (cb *testCallbacks) run(ctx context.Context, le *LeaderElector, identity string) chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- le.Run(ctx, identity,
+			func(ctx context.Context) {
+				cb.started <- struct{}{}
+				<-ctx.Done()
+			},
+			func() {
+				cb.stopped <- struct{}{}
+			})
+	}()
+	return errCh
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, msg string) {
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatal(msg)
+	}
+}
+
+func TestRunFailover(t *testing.T) {
+	ops := core.New(fake.NewSimpleClientset())
+	le1 := newTestElector(t, ops)
+	le2 := newTestElector(t, ops)
+	cb1, cb2 := newTestCallbacks(), newTestCallbacks()
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	errCh1 := cb1.run(ctx1, le1, "id1")
+	waitFor(t, cb1.started, "id1 did not become the leader")
+	require.True(t, le1.IsLeader())
+
+	leader, err := le2.GetLeader()
+	require.NoError(t, err)
+	require.Equal(t, "id1", leader)
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	errCh2 := cb2.run(ctx2, le2, "id2")
+	select {
+	case <-cb2.started:
+		t.Fatal("id2 became the leader while id1 is leading")
+	case <-time.After(2 * time.Second):
+	}
+	require.False(t, le2.IsLeader())
+
+	cancel1()
+	waitFor(t, cb1.stopped, "id1 did not stop leading")
+	require.ErrorIs(t, <-errCh1, context.Canceled)
+	require.False(t, le1.IsLeader())
+
+	waitFor(t, cb2.started, "id2 did not take over the leadership")
+	leader, err = le1.GetLeader()
+	require.NoError(t, err)
+	require.Equal(t, "id2", leader)
+
+	cancel2()
+	waitFor(t, cb2.stopped, "id2 did not stop leading")
+	require.ErrorIs(t, <-errCh2, context.Canceled)
+
+	leader, err = le1.GetLeader()
+	require.NoError(t, err)
+	require.Empty(t, leader)
+}
+
+func TestRunLockLost(t *testing.T) {
+	ops := core.New(fake.NewSimpleClientset())
+	lockLost := make(chan error, 1)
+	le := newTestElector(t, ops, WithLockLostCallback(func(identity string, err error) {
+		require.Equal(t, "id1", identity)
+		lockLost <- err
+	}))
+	cb := newTestCallbacks()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := cb.run(ctx, le, "id1")
+	waitFor(t, cb.started, "id1 did not become the leader")
+
+	admin, err := configmap.NewWithOptions(testName, nil, configmap.WithCoreOps(ops))
+	require.NoError(t, err)
+	require.NoError(t, admin.ForceUnlock(DefaultKey, "testing lock loss"))
+
+	select {
+	case err := <-lockLost:
+		require.ErrorIs(t, err, configmap.ErrConfigMapLockLost)
+	case <-time.After(testTimeout):
+		t.Fatal("lock lost callback was not called")
+	}
+	waitFor(t, cb.stopped, "id1 did not stop leading")
+
+	// the elector campaigns again after losing the lock
+	waitFor(t, cb.started, "id1 did not become the leader again")
+	cancel()
+	waitFor(t, cb.stopped, "id1 did not stop leading")
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestRunRefreshFailure(t *testing.T) {
+	var unreachable atomic.Bool
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if unreachable.Load() {
+			return true, nil, k8s_errors.NewServiceUnavailable("apiserver unreachable")
+		}
+		return false, nil, nil
+	})
+	lockLost := make(chan error, 1)
+	le := newTestElector(t, core.New(clientset), WithLockLostCallback(func(identity string, err error) {
+		lockLost <- err
+	}))
+	cb := newTestCallbacks()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := cb.run(ctx, le, "id1")
+	waitFor(t, cb.started, "id1 did not become the leader")
+
+	// a leader cut off from the apiserver stops leading before its lock expires and can be taken
+	// by another candidate
+	unreachable.Store(true)
+	select {
+	case <-cb.stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("id1 kept leading past the lock TTL")
+	}
+	require.False(t, le.IsLeader())
+	require.ErrorIs(t, <-lockLost, configmap.ErrConfigMapLockLost)
+
+	cancel()
+	require.ErrorIs(t, <-errCh, context.Canceled)
+}
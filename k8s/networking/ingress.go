@@ -88,7 +88,7 @@ func (c *Client) ValidateIngress(ingress *v1beta1.Ingress, timeout, retryInterva
 		}
 		return "", false, nil
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("networking.ValidateIngress")); err != nil {
 		return err
 	}
 	return nil
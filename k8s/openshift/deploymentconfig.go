@@ -197,7 +197,7 @@ func (c *Client) ValidateDeploymentConfig(deployment *ocpappsv1api.DeploymentCon
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("openshift.ValidateDeploymentConfig")); err != nil {
 		return err
 	}
 	return nil
@@ -239,7 +239,7 @@ func (c *Client) ValidateTerminatedDeploymentConfig(deployment *ocpappsv1api.Dep
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, 10*time.Minute, 10*time.Second); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, 10*time.Minute, 10*time.Second, task.WithOperation("openshift.ValidateTerminatedDeploymentConfig")); err != nil {
 		return err
 	}
 	return nil
@@ -7,6 +7,7 @@ import (
 
 	storkv1alpha1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +30,8 @@ type ApplicationBackupRestoreOps interface {
 	ValidateApplicationBackup(string, string, time.Duration, time.Duration) error
 	// WatchApplicationBackup watch the ApplicationBackup
 	WatchApplicationBackup(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchApplicationBackupWithContext watches the application backups until ctx is done, the watcher is stopped or fn returns an error
+	WatchApplicationBackupWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 	// CreateApplicationRestore creates the ApplicationRestore
 	CreateApplicationRestore(*storkv1alpha1.ApplicationRestore) (*storkv1alpha1.ApplicationRestore, error)
 	// GetApplicationRestore gets the ApplicationRestore
@@ -43,6 +46,8 @@ type ApplicationBackupRestoreOps interface {
 	ValidateApplicationRestore(string, string, time.Duration, time.Duration) error
 	// WatchApplicationRestore watch the ApplicationRestore
 	WatchApplicationRestore(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchApplicationRestoreWithContext watches the application restores until ctx is done, the watcher is stopped or fn returns an error
+	WatchApplicationRestoreWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 	// GetApplicationBackupSchedule gets the ApplicationBackupSchedule
 	GetApplicationBackupSchedule(string, string) (*storkv1alpha1.ApplicationBackupSchedule, error)
 	// CreateApplicationBackupSchedule creates an ApplicationBackupSchedule
@@ -61,6 +66,8 @@ type ApplicationBackupRestoreOps interface {
 		map[storkv1alpha1.SchedulePolicyType][]*storkv1alpha1.ScheduledApplicationBackupStatus, error)
 	// WatchApplicationBackupSchedule watch the ApplicationBackupSchedule objects
 	WatchApplicationBackupSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchApplicationBackupScheduleWithContext watches the application backup schedules until ctx is done, the watcher is stopped or fn returns an error
+	WatchApplicationBackupScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // CreateApplicationBackup creates the ApplicationBackup
@@ -128,7 +135,7 @@ func (c *Client) ValidateApplicationBackup(name, namespace string, timeout, retr
 
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateApplicationBackup")); err != nil {
 		return err
 	}
 	return nil
@@ -193,7 +200,7 @@ func (c *Client) ValidateApplicationRestore(name, namespace string, timeout, ret
 			Type: applicationrestore,
 		}
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateApplicationRestore")); err != nil {
 		return err
 	}
 	return nil
@@ -328,7 +335,7 @@ func (c *Client) ValidateApplicationBackupSchedule(name string, namespace string
 		return resp.Status.Items, false, nil
 	}
 
-	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval)
+	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateApplicationBackupSchedule"))
 	if err != nil {
 		return nil, err
 	}
@@ -343,54 +350,63 @@ func (c *Client) ValidateApplicationBackupSchedule(name string, namespace string
 
 // WatchApplicationBackup sets up a watcher that listens for changes on application backups
 func (c *Client) WatchApplicationBackup(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchApplicationBackupWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchApplicationBackupWithContext watches the application backups like WatchApplicationBackup, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchApplicationBackupWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().ApplicationBackups(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.ApplicationBackupList](c.stork.StorkV1alpha1().ApplicationBackups(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for application backups")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.ApplicationBackup{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
 
 // WatchApplicationRestore sets up a watcher that listens for changes on application restores
 func (c *Client) WatchApplicationRestore(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchApplicationRestoreWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchApplicationRestoreWithContext watches the application restores like WatchApplicationRestore, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchApplicationRestoreWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().ApplicationRestores(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.ApplicationRestoreList](c.stork.StorkV1alpha1().ApplicationRestores(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for application restores")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.ApplicationRestore{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
 
 // WatchApplicationBackupSchedule sets up a watcher that listens for changes on applicationbackup schedules
 func (c *Client) WatchApplicationBackupSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchApplicationBackupScheduleWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchApplicationBackupScheduleWithContext watches the application backup schedules like WatchApplicationBackupSchedule, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchApplicationBackupScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().ApplicationBackupSchedules(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.ApplicationBackupScheduleList](c.stork.StorkV1alpha1().ApplicationBackupSchedules(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for application backup schedules")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.ApplicationBackupSchedule{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
@@ -7,6 +7,7 @@ import (
 
 	storkv1alpha1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +29,8 @@ type ApplicationCloneOps interface {
 	ValidateApplicationClone(string, string, time.Duration, time.Duration) error
 	// WatchApplicationClone watch the ApplicationClone
 	WatchApplicationClone(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchApplicationCloneWithContext watches the application clones until ctx is done, the watcher is stopped or fn returns an error
+	WatchApplicationCloneWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // CreateApplicationClone creates the ApplicationClone
@@ -92,7 +95,7 @@ func (c *Client) ValidateApplicationClone(name, namespace string, timeout, retry
 			Type:  applicationclone,
 		}
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateApplicationClone")); err != nil {
 		return err
 	}
 	return nil
@@ -100,18 +103,21 @@ func (c *Client) ValidateApplicationClone(name, namespace string, timeout, retry
 
 // WatchApplicationClone sets up a watcher that listens for changes on application backups
 func (c *Client) WatchApplicationClone(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchApplicationCloneWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchApplicationCloneWithContext watches the application clones like WatchApplicationClone, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchApplicationCloneWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().ApplicationClones(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.ApplicationCloneList](c.stork.StorkV1alpha1().ApplicationClones(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for application clones")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.ApplicationClone{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
@@ -87,7 +87,7 @@ func (c *Client) ValidateApplicationRegistration(name string, timeout, retryInte
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateApplicationRegistration")); err != nil {
 		return err
 	}
 	return nil
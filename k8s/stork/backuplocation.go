@@ -114,7 +114,7 @@ func (c *Client) ValidateBackupLocation(name, namespace string, timeout, retryIn
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateBackupLocation")); err != nil {
 		return err
 	}
 	return nil
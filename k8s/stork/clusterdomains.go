@@ -110,7 +110,7 @@ func (c *Client) ValidateClusterDomainsStatus(name string, domainMap map[string]
 		return "", false, nil
 
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateClusterDomainsStatus")); err != nil {
 		return err
 	}
 
@@ -187,7 +187,7 @@ func (c *Client) ValidateClusterDomainUpdate(name string, timeout, retryInterval
 			Type:  resp,
 		}
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateClusterDomainUpdate")); err != nil {
 		return err
 	}
 
@@ -7,6 +7,7 @@ import (
 
 	storkv1alpha1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +29,8 @@ type ClusterPairOps interface {
 	ValidateClusterPair(string, string, time.Duration, time.Duration) error
 	// WatchClusterPair watch the ClusterPair object
 	WatchClusterPair(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchClusterPairWithContext watches the cluster pairs until ctx is done, the watcher is stopped or fn returns an error
+	WatchClusterPairWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // CreateClusterPair creates the ClusterPair
@@ -103,7 +106,7 @@ func (c *Client) ValidateClusterPair(name string, namespace string, timeout, ret
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateClusterPair")); err != nil {
 		return err
 	}
 
@@ -112,18 +115,21 @@ func (c *Client) ValidateClusterPair(name string, namespace string, timeout, ret
 
 // WatchClusterPair sets up a watcher that listens for changes on cluster pair objects
 func (c *Client) WatchClusterPair(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchClusterPairWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchClusterPairWithContext watches the cluster pairs like WatchClusterPair, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchClusterPairWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().ClusterPairs(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.ClusterPairList](c.stork.StorkV1alpha1().ClusterPairs(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for cluster pair")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.ClusterPair{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
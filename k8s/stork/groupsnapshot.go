@@ -134,7 +134,7 @@ func (c *Client) ValidateGroupSnapshot(name, namespace string, retry bool, timeo
 	}
 
 	if retry {
-		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+		if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateGroupSnapshot")); err != nil {
 			return err
 		}
 	} else {
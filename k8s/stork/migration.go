@@ -7,6 +7,7 @@ import (
 
 	storkv1alpha1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,8 +45,12 @@ type MigrationOps interface {
 		map[storkv1alpha1.SchedulePolicyType][]*storkv1alpha1.ScheduledMigrationStatus, error)
 	// WatchMigration watch the Migration object
 	WatchMigration(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchMigrationWithContext watches the migrations until ctx is done, the watcher is stopped or fn returns an error
+	WatchMigrationWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 	// WatchMigrationSchedule watch the MigrationSchedule object
 	WatchMigrationSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchMigrationScheduleWithContext watches the migration schedules until ctx is done, the watcher is stopped or fn returns an error
+	WatchMigrationScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // GetMigration gets the Migration
@@ -118,7 +123,7 @@ func (c *Client) ValidateMigration(name string, namespace string, timeout, retry
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateMigration")); err != nil {
 		return err
 	}
 
@@ -240,7 +245,7 @@ func (c *Client) ValidateMigrationSchedule(name string, namespace string, timeou
 		return resp.Status.Items, false, nil
 	}
 
-	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval)
+	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateMigrationSchedule"))
 	if err != nil {
 		return nil, err
 	}
@@ -255,36 +260,42 @@ func (c *Client) ValidateMigrationSchedule(name string, namespace string, timeou
 
 // WatchMigration sets up a watcher that listens for changes on migration objects
 func (c *Client) WatchMigration(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchMigrationWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchMigrationWithContext watches the migrations like WatchMigration, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchMigrationWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().Migrations(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.MigrationList](c.stork.StorkV1alpha1().Migrations(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for migration")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.Migration{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
 
 // WatchMigrationSchedule sets up a watcher that listens for changes on migration schedule objects
 func (c *Client) WatchMigrationSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchMigrationScheduleWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchMigrationScheduleWithContext watches the migration schedules like WatchMigrationSchedule, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchMigrationScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().MigrationSchedules(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.MigrationScheduleList](c.stork.StorkV1alpha1().MigrationSchedules(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for migrationschedules")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.MigrationSchedule{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
@@ -106,7 +106,7 @@ func (c *Client) ValidatePlatformCredential(name, namespace string, timeout, ret
 		return "", false, nil
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidatePlatformCredential")); err != nil {
 		return err
 	}
 	return nil
@@ -105,7 +105,7 @@ func (c *Client) ValidateResourceTransformation(name string, namespace string, t
 		}
 	}
 
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateResourceTransformation")); err != nil {
 		return err
 	}
 
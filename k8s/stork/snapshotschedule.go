@@ -8,6 +8,7 @@ import (
 	snapv1 "github.com/kubernetes-incubator/external-storage/snapshot/pkg/apis/crd/v1"
 	storkv1alpha1 "github.com/libopenstorage/stork/pkg/apis/stork/v1alpha1"
 	"github.com/portworx/sched-ops/k8s/errors"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
 	"github.com/portworx/sched-ops/task"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,6 +34,8 @@ type SnapshotScheduleOps interface {
 		map[storkv1alpha1.SchedulePolicyType][]*storkv1alpha1.ScheduledVolumeSnapshotStatus, error)
 	// sets up a watcher that listens for changes on volume snapshot schedules
 	WatchVolumeSnapshotSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error
+	// WatchVolumeSnapshotScheduleWithContext watches the volume snapshot schedules until ctx is done, the watcher is stopped or fn returns an error
+	WatchVolumeSnapshotScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error)
 }
 
 // CreateSnapshotSchedule creates a SnapshotSchedule
@@ -148,7 +151,7 @@ func (c *Client) ValidateSnapshotSchedule(name string, namespace string, timeout
 		return resp.Status.Items, false, nil
 	}
 
-	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval)
+	ret, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateSnapshotSchedule"))
 	if err != nil {
 		return nil, err
 	}
@@ -163,18 +166,21 @@ func (c *Client) ValidateSnapshotSchedule(name string, namespace string, timeout
 
 // WatchVolumeSnapshotSchedule sets up a watcher that listens for changes on volume snapshot schedules
 func (c *Client) WatchVolumeSnapshotSchedule(namespace string, fn WatchFunc, listOptions metav1.ListOptions) error {
+	return schedwatch.InBackground(c.WatchVolumeSnapshotScheduleWithContext(context.Background(), namespace, schedwatch.ObjectFunc(fn), listOptions))
+}
+
+// WatchVolumeSnapshotScheduleWithContext watches the volume snapshot schedules like WatchVolumeSnapshotSchedule, calling fn with the type and object of each change
+// until ctx is done, the returned watcher is stopped or fn returns an error.
+func (c *Client) WatchVolumeSnapshotScheduleWithContext(ctx context.Context, namespace string, fn schedwatch.EventFunc, listOptions metav1.ListOptions) (*schedwatch.Watcher, error) {
 	if err := c.initClient(); err != nil {
-		return err
+		return nil, err
 	}
 
-	listOptions.Watch = true
-	watchInterface, err := c.stork.StorkV1alpha1().VolumeSnapshotSchedules(namespace).Watch(context.TODO(), listOptions)
+	source := schedwatch.ForClient[*storkv1alpha1.VolumeSnapshotScheduleList](c.stork.StorkV1alpha1().VolumeSnapshotSchedules(namespace))
+	w, err := schedwatch.Start(ctx, source, fn, &schedwatch.Options{ListOptions: listOptions})
 	if err != nil {
 		logrus.WithError(err).Error("error invoking the watch api for snapshot schedules")
-		return err
+		return nil, err
 	}
-
-	// fire off watch function
-	go c.handleWatch(watchInterface, &storkv1alpha1.VolumeSnapshotSchedule{}, "", fn, listOptions)
-	return nil
+	return w, nil
 }
@@ -90,7 +90,7 @@ func (c *Client) ValidateVolumeSnapshotRestore(name, namespace string, timeout,
 			Type: snapRestore,
 		}
 	}
-	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval); err != nil {
+	if _, err := task.DoRetryWithTimeout(t, timeout, retryInterval, task.WithOperation("stork.ValidateVolumeSnapshotRestore")); err != nil {
 		return err
 	}
 
@@ -0,0 +1,205 @@
+package wait
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Deleted is met once the object does not exist
+func Deleted(obj *unstructured.Unstructured) (bool, error) {
+	return obj == nil, nil
+}
+
+// Ready is met once the object is ready, using the readiness of its kind:
+//   - Deployment: like kubectl rollout status, the controller observed the current generation, all
+//     the replicas are updated and available, and no old replica is left
+//   - ReplicaSet: all the replicas are ready
+//   - StatefulSet: all the replicas are ready and, like kubectl rollout status, the replicas above
+//     the rolling update partition are updated, or the current revision is the update revision
+//   - DaemonSet: the daemon pods are updated and ready on all the nodes they should run on
+//   - PersistentVolumeClaim: the claim is bound
+//   - CustomResourceDefinition: the definition is established
+//   - others, like Pod and Node: the Ready status condition is True
+func Ready(obj *unstructured.Unstructured) (bool, error) {
+	if obj == nil || !observed(obj) {
+		return false, nil
+	}
+
+	switch obj.GetKind() {
+	case "Deployment":
+		return deploymentRolledOut(obj)
+	case "StatefulSet", "ReplicaSet":
+		replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			replicas = 1
+		}
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if ready != replicas {
+			return false, nil
+		}
+		if obj.GetKind() == "StatefulSet" {
+			return statefulSetUpdated(obj, replicas), nil
+		}
+		return true, nil
+	case "DaemonSet":
+		desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+		updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+		return ready == desired && updated == desired, nil
+	case "PersistentVolumeClaim":
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		return phase == "Bound", nil
+	case "CustomResourceDefinition":
+		return ConditionTrue("Established")(obj)
+	default:
+		return ConditionTrue("Ready")(obj)
+	}
+}
+
+// Available is met once the Available status condition of the object, e.g. a Deployment, is True
+func Available(obj *unstructured.Unstructured) (bool, error) {
+	return ConditionTrue("Available")(obj)
+}
+
+// Complete is met once the object completed: a Pod succeeded, or the Complete status condition of
+// other objects, e.g. a Job, is True. It returns an error if the Pod or Job failed.
+func Complete(obj *unstructured.Unstructured) (bool, error) {
+	if obj == nil {
+		return false, nil
+	}
+
+	if obj.GetKind() == "Pod" {
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		switch phase {
+		case "Succeeded":
+			return true, nil
+		case "Failed":
+			return false, fmt.Errorf("pod %s failed", objectName(obj))
+		}
+		return false, nil
+	}
+
+	if failed, _ := ConditionTrue("Failed")(obj); failed {
+		return false, fmt.Errorf("%s %s failed: %s", obj.GetKind(), objectName(obj), conditionMessage(obj, "Failed"))
+	}
+	return ConditionTrue("Complete")(obj)
+}
+
+// ConditionTrue returns a condition met once the status condition of the given type of the object is
+// True, and reflects the latest generation of the object.
+func ConditionTrue(conditionType string) Condition {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		if obj == nil || !observed(obj) {
+			return false, nil
+		}
+
+		condition := findCondition(obj, conditionType)
+		if condition == nil {
+			return false, nil
+		}
+		if generation, found, _ := unstructured.NestedInt64(condition, "observedGeneration"); found && generation < obj.GetGeneration() {
+			return false, nil
+		}
+		status, _, _ := unstructured.NestedString(condition, "status")
+		return status == "True", nil
+	}
+}
+
+// JSONPathEquals returns a condition met once the value at the given JSONPath template of the object,
+// e.g. {.status.phase}, equals value.
+func JSONPathEquals(template, value string) Condition {
+	return func(obj *unstructured.Unstructured) (bool, error) {
+		path := jsonpath.New("wait")
+		if err := path.Parse(template); err != nil {
+			return false, fmt.Errorf("invalid JSONPath %s: %v", template, err)
+		}
+		if obj == nil {
+			return false, nil
+		}
+
+		results, err := path.FindResults(obj.Object)
+		if err != nil || len(results) == 0 || len(results[0]) == 0 {
+			// the field is not set yet
+			return false, nil
+		}
+		if len(results) > 1 || len(results[0]) > 1 {
+			return false, fmt.Errorf("JSONPath %s matches more than one value", template)
+		}
+		var buf bytes.Buffer
+		if err := path.PrintResults(&buf, results[0]); err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(buf.String()) == value, nil
+	}
+}
+
+// statefulSetUpdated returns true once the rolling update of the StatefulSet is done. Only the replicas
+// with an ordinal of at least the partition are updated, so the current revision never becomes the
+// update revision when a partition is set.
+// deploymentRolledOut returns true once the rollout of the Deployment is complete, using the checks
+// of kubectl rollout status. Ready replicas are not enough: during a rollout, the ready replicas of
+// the old ReplicaSet can match the count while the new ones are not available yet.
+func deploymentRolledOut(obj *unstructured.Unstructured) (bool, error) {
+	generation, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if !found || generation < obj.GetGeneration() {
+		return false, nil
+	}
+
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		replicas = 1
+	}
+	statusReplicas, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	// the old replicas are counted by status.replicas until they are terminated
+	return updated >= replicas && statusReplicas <= updated && available >= updated, nil
+}
+
+func statefulSetUpdated(obj *unstructured.Unstructured, replicas int64) bool {
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy != "" && strategy != "RollingUpdate" {
+		// the pods of an OnDelete StatefulSet are only updated once they are deleted
+		return true
+	}
+
+	if partition, found, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition"); found && partition > 0 {
+		updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+		return updated >= replicas-partition
+	}
+	current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	return current == update
+}
+
+// observed returns false if the status of the object reports a generation older than the object's
+func observed(obj *unstructured.Unstructured) bool {
+	generation, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	return !found || generation >= obj.GetGeneration()
+}
+
+func findCondition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
+
+func conditionMessage(obj *unstructured.Unstructured, conditionType string) string {
+	message, _, _ := unstructured.NestedString(findCondition(obj, conditionType), "message")
+	return message
+}
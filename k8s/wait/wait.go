@@ -0,0 +1,102 @@
+// Package wait waits for kubernetes objects to meet a condition, like kubectl wait.
+package wait
+
+import (
+	"context"
+	"fmt"
+
+	schedynamic "github.com/portworx/sched-ops/k8s/dynamic"
+	schedwatch "github.com/portworx/sched-ops/k8s/watch"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// Client gives access to the resources of the objects, like the client of the dynamic package
+type Client interface {
+	// GetResourceClient returns the client of the resource of the object, in its namespace if it has one
+	GetResourceClient(object runtime.Object) (dynamic.ResourceInterface, error)
+}
+
+// Condition returns true once the object is in the awaited state. The object is nil if it does not
+// exist. Returning an error stops the wait with that error, e.g. when the object failed.
+type Condition func(obj *unstructured.Unstructured) (bool, error)
+
+// For waits until the condition is met by the given object or ctx is done. The object can be typed
+// or unstructured, only its kind, name and namespace are used. The object is watched rather than
+// polled.
+func For(ctx context.Context, client Client, obj runtime.Object, condition Condition) error {
+	u, err := schedynamic.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	resourceClient, err := client.GetResourceClient(u)
+	if err != nil {
+		return err
+	}
+
+	listOptions := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", u.GetName()).String(),
+	}
+	list, err := resourceClient.List(ctx, listOptions)
+	if err != nil {
+		return err
+	}
+	var current *unstructured.Unstructured
+	for i := range list.Items {
+		if list.Items[i].GetName() == u.GetName() {
+			current = &list.Items[i]
+		}
+	}
+	if met, err := condition(current); err != nil || met {
+		return err
+	}
+
+	// the watch starts from the list, so that no change is missed in between, and knows the object
+	// so that it reports its deletion if it is deleted while the watch is expired
+	listOptions.ResourceVersion = list.GetResourceVersion()
+	var listed []runtime.Object
+	if current != nil {
+		listed = append(listed, current)
+	}
+	var met bool
+	w, err := schedwatch.Start(ctx, schedwatch.ForClient[*unstructured.UnstructuredList](resourceClient), func(event watch.Event) error {
+		current, ok := event.Object.(*unstructured.Unstructured)
+		if !ok || current.GetName() != u.GetName() {
+			return nil
+		}
+		if event.Type == watch.Deleted {
+			current = nil
+		}
+		var err error
+		if met, err = condition(current); err != nil {
+			return err
+		}
+		if met {
+			return schedwatch.ErrStopWatch
+		}
+		return nil
+	}, &schedwatch.Options{ListOptions: listOptions, Listed: listed})
+	if err != nil {
+		return err
+	}
+
+	<-w.Done()
+	if met {
+		return nil
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("timed out waiting for the condition on %s %s: %w", u.GetKind(), objectName(u), ctx.Err())
+}
+
+func objectName(obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,218 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	schedynamic "github.com/portworx/sched-ops/k8s/dynamic"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var deploymentsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+func newDeployment(replicas, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "dep1", Namespace: "ns1", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           replicas,
+			ReadyReplicas:      ready,
+			UpdatedReplicas:    ready,
+			AvailableReplicas:  ready,
+		},
+	}
+}
+
+func toUnstructuredObject(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	u, err := schedynamic.ToUnstructured(obj)
+	require.NoError(t, err)
+	return u
+}
+
+func TestFor(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"}}
+	fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, newDeployment(2, 0), pod)
+	client := schedynamic.New(fakeClient)
+
+	// the typed object lacks its kind, which is found from the scheme
+	errChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		errChan <- For(ctx, client, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "dep1", Namespace: "ns1"}}, Ready)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err := fakeClient.Resource(deploymentsResource).Namespace("ns1").Update(context.TODO(),
+		toUnstructuredObject(t, newDeployment(2, 2)), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, <-errChan)
+
+	// an already met condition returns right away
+	require.NoError(t, For(context.Background(), client, newDeployment(2, 2), Ready))
+
+	go func() {
+		errChan <- For(context.Background(), client, pod, Deleted)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, fakeClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).
+		Namespace("ns1").Delete(context.TODO(), "pod1", metav1.DeleteOptions{}))
+	require.NoError(t, <-errChan)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = For(ctx, client, newDeployment(2, 2), Deleted)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestForDeletedWhileExpired(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1", ResourceVersion: "5"}}
+	fakeClient := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pod)
+	// the fake does not set the resource version of the lists, which a watch starts from
+	podsResource := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	fakeClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := fakeClient.Tracker().List(podsResource, schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, "ns1")
+		if err != nil {
+			return true, nil, err
+		}
+		list := obj.(*unstructured.UnstructuredList)
+		list.SetResourceVersion("10")
+		return true, list, nil
+	})
+	watchers := make(chan *watch.FakeWatcher, 2)
+	fakeClient.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+
+	errChan := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		errChan <- For(ctx, schedynamic.New(fakeClient), pod, Deleted)
+	}()
+
+	// the pod is deleted while the watch is expired, so the list of the pods does not have it anymore
+	w := <-watchers
+	require.NoError(t, fakeClient.Resource(podsResource).Namespace("ns1").Delete(context.TODO(), "pod1", metav1.DeleteOptions{}))
+	w.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+	require.NoError(t, <-errChan)
+}
+
+func TestConditions(t *testing.T) {
+	ready, err := Ready(toUnstructuredObject(t, newDeployment(2, 1)))
+	require.NoError(t, err)
+	require.False(t, ready)
+	stale := newDeployment(2, 2)
+	stale.Generation = 3
+	ready, err = Ready(toUnstructuredObject(t, stale))
+	require.NoError(t, err)
+	require.False(t, ready, "Expected a status of an older generation not to be ready")
+
+	// with a 100% max surge, the new pods are all created while the old ones still serve
+	rollout := newDeployment(3, 3)
+	rollout.Status.Replicas = 6
+	rollout.Status.AvailableReplicas = 3
+	rollout.Status.UpdatedReplicas = 3
+	ready, err = Ready(toUnstructuredObject(t, rollout))
+	require.NoError(t, err)
+	require.False(t, ready, "Expected a Deployment with old replicas left not to be ready")
+	rollout.Status.Replicas = 3
+	rollout.Status.AvailableReplicas = 2
+	ready, err = Ready(toUnstructuredObject(t, rollout))
+	require.NoError(t, err)
+	require.False(t, ready, "Expected a Deployment with unavailable updated replicas not to be ready")
+	rollout.Status.AvailableReplicas = 3
+	ready, err = Ready(toUnstructuredObject(t, rollout))
+	require.NoError(t, err)
+	require.True(t, ready)
+
+	replicas := int32(3)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "sts1", Namespace: "ns1"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status: appsv1.StatefulSetStatus{
+			ReadyReplicas:   3,
+			UpdatedReplicas: 1,
+			CurrentRevision: "sts1-1",
+			UpdateRevision:  "sts1-2",
+		},
+	}
+	ready, err = Ready(toUnstructuredObject(t, statefulSet))
+	require.NoError(t, err)
+	require.False(t, ready, "Expected a StatefulSet being updated not to be ready")
+	partition := int32(2)
+	statefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+	}
+	ready, err = Ready(toUnstructuredObject(t, statefulSet))
+	require.NoError(t, err)
+	require.True(t, ready, "Expected the replicas above the partition to be enough")
+	statefulSet.Spec.UpdateStrategy.RollingUpdate = nil
+	statefulSet.Status.UpdatedReplicas = 3
+	statefulSet.Status.CurrentRevision = "sts1-2"
+	ready, err = Ready(toUnstructuredObject(t, statefulSet))
+	require.NoError(t, err)
+	require.True(t, ready)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "ns1"},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}},
+	}
+	complete, err := Complete(toUnstructuredObject(t, job))
+	require.NoError(t, err)
+	require.True(t, complete)
+	job.Status.Conditions[0] = batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit"}
+	_, err = Complete(toUnstructuredObject(t, job))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "backoff limit")
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	u := toUnstructuredObject(t, pod)
+	require.Equal(t, "Pod", u.GetKind())
+	for _, condition := range []Condition{Ready, ConditionTrue("Ready"), JSONPathEquals("{.status.phase}", "Running")} {
+		met, err := condition(u)
+		require.NoError(t, err)
+		require.True(t, met)
+	}
+	met, err := JSONPathEquals("{.status.podIP}", "10.0.0.1")(u)
+	require.NoError(t, err)
+	require.False(t, met, "Expected an unset field not to match")
+	met, err = JSONPathEquals("{.status.conditions[*].type}", "Ready")(u)
+	require.NoError(t, err)
+	require.True(t, met, "Expected the single condition type to match")
+	pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue})
+	_, err = JSONPathEquals("{.status.conditions[*].type}", "Ready")(toUnstructuredObject(t, pod))
+	require.Error(t, err, "Expected several values not to be compared")
+	_, err = JSONPathEquals("{.status", "Running")(u)
+	require.Error(t, err)
+	available, err := Available(nil)
+	require.NoError(t, err)
+	require.False(t, available)
+}
@@ -0,0 +1,378 @@
+// Package watch provides resumable watches of kubernetes resources.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/portworx/sched-ops/task"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// defaultRetryInterval is the default Options.RetryInterval
+	defaultRetryInterval = 10 * time.Second
+	// defaultRetryTimeout is the default Options.RetryTimeout
+	defaultRetryTimeout = 10 * time.Minute
+)
+
+// ListFunc lists the watched objects
+type ListFunc func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error)
+
+// WatchFunc starts a watch of the objects
+type WatchFunc func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error)
+
+// Source is how the objects are listed and watched, see ForClient for the typed clients and
+// ForResource for any resource of the dynamic client.
+type Source struct {
+	List  ListFunc
+	Watch WatchFunc
+}
+
+// TypedClient is implemented by the typed clients of a resource, L being the list type
+type TypedClient[L runtime.Object] interface {
+	List(ctx context.Context, opts metav1.ListOptions) (L, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// EventFunc is invoked in order with the Added, Modified and Deleted events of the watch. Returning
+// ErrStopWatch stops the watch, any other error stops it with that error.
+type EventFunc func(event watch.Event) error
+
+// Options are the options of a watch
+type Options struct {
+	// ListOptions selects the watched objects. If a resource version is given, the watch starts
+	// from it, otherwise the objects are listed first and reported as Added events.
+	ListOptions metav1.ListOptions
+	// Listed are the objects of the list the resource version of ListOptions comes from, if the
+	// caller listed them. Like the objects listed by the watch, they are reported as Deleted if they
+	// are missing when the objects are listed again.
+	Listed []runtime.Object
+	// RetryInterval is the time between the attempts to re-establish the watch. It defaults to 10 seconds.
+	RetryInterval time.Duration
+	// RetryTimeout is how long the watch is re-established before it fails. It defaults to 10 minutes.
+	RetryTimeout time.Duration
+}
+
+// Watcher is a watch started by Start. It resumes from the last resource version it saw when the
+// watch is closed, and lists the objects again when that version is too old. The objects it saw
+// which are missing from that list are then reported as Deleted events, with their last known state.
+type Watcher struct {
+	source        Source
+	fn            EventFunc
+	listOptions   metav1.ListOptions
+	retryInterval time.Duration
+	retryTimeout  time.Duration
+
+	// resourceVersion is the last resource version seen, empty if the objects must be listed
+	resourceVersion string
+	// known are the last known states of the objects which exist, by namespace/name
+	known map[string]runtime.Object
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// connection is an established watch with the objects listed before it
+type connection struct {
+	watch           watch.Interface
+	resourceVersion string
+	// hasList is true if the objects were listed before the watch, listed then being the objects
+	hasList bool
+	listed  []runtime.Object
+}
+
+var (
+	// ErrStopWatch is returned by an EventFunc to stop the watch without error
+	ErrStopWatch = errors.New("stop watch")
+
+	errResourceVersionExpired = errors.New("resource version of the watch expired")
+)
+
+// ForClient returns the source of the objects of a typed client, e.g.
+// ForClient[*corev1.PodList](clientset.CoreV1().Pods(namespace))
+func ForClient[L runtime.Object](client TypedClient[L]) Source {
+	return Source{
+		List: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return client.List(ctx, options)
+		},
+		Watch: client.Watch,
+	}
+}
+
+// ForResource returns the source of the objects of the given resource in the namespace, or of all
+// namespaces if it is empty.
+func ForResource(client dynamic.Interface, gvr schema.GroupVersionResource, namespace string) Source {
+	resourceClient := client.Resource(gvr).Namespace(namespace)
+	return Source{
+		List: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return resourceClient.List(ctx, options)
+		},
+		Watch: resourceClient.Watch,
+	}
+}
+
+// ObjectFunc adapts a callback invoked with the object of every event, like the WatchFunc of the core
+// and stork packages, to an EventFunc. Returning ErrStopWatch stops the watch; other errors of fn are
+// logged and ignored.
+func ObjectFunc(fn func(object runtime.Object) error) EventFunc {
+	return func(event watch.Event) error {
+		err := fn(event.Object)
+		if errors.Is(err, ErrStopWatch) {
+			return err
+		}
+		if err != nil {
+			logrus.WithError(err).Debug("watch function failed")
+		}
+		return nil
+	}
+}
+
+// InBackground returns err if the watch could not be started. Otherwise it lets the watch run and
+// logs its failure once it ends, for the callers only reporting the error of starting it.
+func InBackground(w *Watcher, err error) error {
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-w.Done()
+		if err := w.Err(); err != nil {
+			logrus.WithError(err).Error("Kubernetes watch failed")
+		}
+	}()
+	return nil
+}
+
+// Start establishes the watch and calls fn with its events until ctx is done, the watcher is
+// stopped or it fails. An error is returned if the watch cannot be established.
+func Start(ctx context.Context, source Source, fn EventFunc, opts *Options) (*Watcher, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	w := &Watcher{
+		source:          source,
+		fn:              fn,
+		listOptions:     opts.ListOptions,
+		retryInterval:   opts.RetryInterval,
+		retryTimeout:    opts.RetryTimeout,
+		resourceVersion: opts.ListOptions.ResourceVersion,
+		known:           make(map[string]runtime.Object),
+		done:            make(chan struct{}),
+	}
+	for _, obj := range opts.Listed {
+		w.known[objectKey(obj)] = obj
+	}
+	if w.retryInterval <= 0 {
+		w.retryInterval = defaultRetryInterval
+	}
+	if w.retryTimeout <= 0 {
+		w.retryTimeout = defaultRetryTimeout
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	conn, err := w.connect(ctx, w.resourceVersion)
+	if err != nil {
+		w.cancel()
+		return nil, err
+	}
+	go w.run(ctx, conn)
+	return w, nil
+}
+
+// Stop stops the watch. Done is closed once fn is not called anymore.
+func (w *Watcher) Stop() {
+	w.cancel()
+}
+
+// Done returns a channel which is closed when the watch ends
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Err returns the error the watch failed with once Done is closed. It is nil if the watch was
+// stopped, its context is done or fn returned ErrStopWatch.
+func (w *Watcher) Err() error {
+	select {
+	case <-w.done:
+		return w.err
+	default:
+		return nil
+	}
+}
+
+func (w *Watcher) run(ctx context.Context, conn *connection) {
+	defer close(w.done)
+	defer w.cancel()
+
+	for {
+		err := w.consume(ctx, conn)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrStopWatch) {
+			return
+		}
+		if err != nil && err != errResourceVersionExpired {
+			w.err = err
+			return
+		}
+		if err == errResourceVersionExpired {
+			logrus.Debug("Kubernetes watch expired (listing the objects again)")
+			w.resourceVersion = ""
+		} else {
+			logrus.Debug("Kubernetes watch closed (attempting to re-establish)")
+		}
+
+		if conn, err = w.reconnect(ctx); err != nil {
+			if ctx.Err() == nil {
+				w.err = fmt.Errorf("could not re-establish the watch: %w", err)
+			}
+			return
+		}
+		logrus.Debug("watch re-established")
+	}
+}
+
+// consume delivers the listed objects, the deletions of the objects missing from the list and the
+// events of the connection to fn. It returns nil when the watch is closed, errResourceVersionExpired
+// if it must be listed again, or the error of fn.
+func (w *Watcher) consume(ctx context.Context, conn *connection) error {
+	defer conn.watch.Stop()
+
+	if conn.hasList {
+		deleted := w.known
+		w.known = make(map[string]runtime.Object, len(conn.listed))
+		for _, obj := range conn.listed {
+			key := objectKey(obj)
+			delete(deleted, key)
+			w.known[key] = obj
+		}
+		for _, obj := range conn.listed {
+			if err := w.fn(watch.Event{Type: watch.Added, Object: obj}); err != nil {
+				return err
+			}
+		}
+		// the objects deleted while the watch was down
+		for _, obj := range deleted {
+			if err := w.fn(watch.Event{Type: watch.Deleted, Object: obj}); err != nil {
+				return err
+			}
+		}
+	}
+	w.resourceVersion = conn.resourceVersion
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, more := <-conn.watch.ResultChan():
+			if !more {
+				return nil
+			}
+			if event.Type == watch.Error {
+				err := apierrors.FromObject(event.Object)
+				if apierrors.IsGone(err) || apierrors.IsResourceExpired(err) {
+					return errResourceVersionExpired
+				}
+				logrus.WithError(err).Debug("Kubernetes watch failed")
+				return nil
+			}
+
+			if accessor, err := meta.Accessor(event.Object); err == nil && accessor.GetResourceVersion() != "" {
+				w.resourceVersion = accessor.GetResourceVersion()
+			}
+			switch event.Type {
+			case watch.Bookmark:
+				continue
+			case watch.Added, watch.Modified:
+				w.known[objectKey(event.Object)] = event.Object
+			case watch.Deleted:
+				delete(w.known, objectKey(event.Object))
+			}
+			if err := w.fn(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// reconnect re-establishes the watch from the last resource version seen until the retry timeout
+func (w *Watcher) reconnect(ctx context.Context) (*connection, error) {
+	retryCtx, cancel := context.WithTimeout(ctx, w.retryTimeout)
+	defer cancel()
+
+	resourceVersion := w.resourceVersion
+	t := func() (*connection, bool, error) {
+		// The attempt is bounded by the retry timeout, but the established watch must outlive it
+		attemptCtx, cancelAttempt := context.WithCancel(ctx)
+		stop := context.AfterFunc(retryCtx, cancelAttempt)
+		conn, err := w.connect(attemptCtx, resourceVersion)
+		if err == nil && !stop() {
+			conn.watch.Stop()
+			conn, err = nil, retryCtx.Err()
+		}
+		if err != nil {
+			cancelAttempt()
+		}
+		if apierrors.IsGone(err) || apierrors.IsResourceExpired(err) {
+			resourceVersion = ""
+		}
+		return conn, true, err
+	}
+	return task.DoRetryWithContext(retryCtx, t, task.ConstantBackoff(w.retryInterval), task.WithOperation("watch.reconnect"))
+}
+
+// connect starts a watch from the given resource version, listing the objects first if it is empty
+func (w *Watcher) connect(ctx context.Context, resourceVersion string) (*connection, error) {
+	conn := &connection{resourceVersion: resourceVersion, hasList: resourceVersion == ""}
+	if conn.hasList {
+		listOptions := w.listOptions
+		listOptions.Watch = false
+		listOptions.ResourceVersion = ""
+		list, err := w.source.List(ctx, listOptions)
+		if err != nil {
+			return nil, err
+		}
+		if conn.listed, err = meta.ExtractList(list); err != nil {
+			return nil, err
+		}
+		listMeta, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, err
+		}
+		conn.resourceVersion = listMeta.GetResourceVersion()
+	}
+
+	watchOptions := w.listOptions
+	watchOptions.Watch = true
+	watchOptions.ResourceVersion = conn.resourceVersion
+	watchOptions.AllowWatchBookmarks = true
+	var err error
+	if conn.watch, err = w.source.Watch(ctx, watchOptions); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// objectKey returns the namespace/name key of the object
+func objectKey(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
@@ -0,0 +1,212 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeServer serves the lists and watches of pods and records the resource versions they are requested from
+type fakeServer struct {
+	mu            sync.Mutex
+	listVersion   string
+	lists         int
+	watchVersions []string
+	watchers      chan *watch.FakeWatcher
+	watchErr      error
+	pods          []corev1.Pod
+}
+
+func newFakeServer() (*fakeServer, Source) {
+	s := &fakeServer{listVersion: "10", watchers: make(chan *watch.FakeWatcher, 10)}
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.lists++
+		return true, &corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: s.listVersion}, Items: s.pods}, nil
+	})
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.watchErr != nil {
+			return true, nil, s.watchErr
+		}
+		s.watchVersions = append(s.watchVersions, action.(k8stesting.WatchActionImpl).WatchRestrictions.ResourceVersion)
+		w := watch.NewFake()
+		s.watchers <- w
+		return true, w, nil
+	})
+	return s, ForClient[*corev1.PodList](clientset.CoreV1().Pods("ns1"))
+}
+
+func (s *fakeServer) nextWatcher(t *testing.T) *watch.FakeWatcher {
+	select {
+	case w := <-s.watchers:
+		return w
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the watch")
+		return nil
+	}
+}
+
+func (s *fakeServer) stats() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists, append([]string(nil), s.watchVersions...)
+}
+
+func newPod(name, resourceVersion string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1", ResourceVersion: resourceVersion}}
+}
+
+func TestWatchResumes(t *testing.T) {
+	server, source := newFakeServer()
+	server.pods = []corev1.Pod{*newPod("pod1", "5")}
+	events := make(chan watch.Event, 10)
+	w, err := Start(context.Background(), source, func(event watch.Event) error {
+		events <- event
+		return nil
+	}, &Options{RetryInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	fw := server.nextWatcher(t)
+	event := <-events
+	require.Equal(t, watch.Added, event.Type)
+	require.Equal(t, "pod1", event.Object.(*corev1.Pod).Name)
+
+	fw.Modify(newPod("pod1", "11"))
+	require.Equal(t, watch.Modified, (<-events).Type)
+	fw.Add(newPod("pod2", "11"))
+	require.Equal(t, watch.Added, (<-events).Type)
+	fw.Action(watch.Bookmark, newPod("", "12"))
+	fw.Stop()
+
+	// the watch resumes from the bookmark without listing again
+	fw = server.nextWatcher(t)
+	lists, versions := server.stats()
+	require.Equal(t, 1, lists)
+	require.Equal(t, []string{"10", "12"}, versions)
+
+	// an expired resource version lists the objects again, and reports the deletion of pod2 which is
+	// missing from the list
+	server.mu.Lock()
+	server.listVersion = "20"
+	server.pods = []corev1.Pod{*newPod("pod1", "15")}
+	server.mu.Unlock()
+	fw.Error(&apierrors.NewResourceExpired("too old resource version").ErrStatus)
+	server.nextWatcher(t)
+	event = <-events
+	require.Equal(t, watch.Added, event.Type)
+	require.Equal(t, "pod1", event.Object.(*corev1.Pod).Name)
+	event = <-events
+	require.Equal(t, watch.Deleted, event.Type)
+	require.Equal(t, "pod2", event.Object.(*corev1.Pod).Name)
+	require.Equal(t, "11", event.Object.(*corev1.Pod).ResourceVersion)
+	lists, versions = server.stats()
+	require.Equal(t, 2, lists)
+	require.Equal(t, []string{"10", "12", "20"}, versions)
+	require.Empty(t, events, "Expected the bookmark not to be delivered")
+
+	w.Stop()
+	<-w.Done()
+	require.NoError(t, w.Err())
+}
+
+func TestWatchFailures(t *testing.T) {
+	server, source := newFakeServer()
+	errStop := errors.New("stop")
+	w, err := Start(context.Background(), source, func(event watch.Event) error {
+		return errStop
+	}, nil)
+	require.NoError(t, err)
+	server.nextWatcher(t).Add(newPod("pod1", "11"))
+	<-w.Done()
+	require.Equal(t, errStop, w.Err(), "Expected the error of fn to stop the watch")
+
+	w, err = Start(context.Background(), source, func(event watch.Event) error {
+		return fmt.Errorf("done: %w", ErrStopWatch)
+	}, nil)
+	require.NoError(t, err)
+	server.nextWatcher(t).Add(newPod("pod1", "11"))
+	<-w.Done()
+	require.NoError(t, w.Err(), "Expected ErrStopWatch to stop the watch without error")
+
+	objects := make(chan runtime.Object, 10)
+	w, err = Start(context.Background(), source, ObjectFunc(func(object runtime.Object) error {
+		objects <- object
+		if object.(*corev1.Pod).Name == "pod2" {
+			return ErrStopWatch
+		}
+		return errStop
+	}), nil)
+	require.NoError(t, err)
+	fw := server.nextWatcher(t)
+	fw.Add(newPod("pod1", "11"))
+	fw.Add(newPod("pod2", "12"))
+	<-w.Done()
+	require.NoError(t, w.Err(), "Expected ObjectFunc to ignore the errors other than ErrStopWatch")
+	require.Len(t, objects, 2)
+
+	w, err = Start(context.Background(), source, func(event watch.Event) error { return nil },
+		&Options{RetryInterval: 10 * time.Millisecond, RetryTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	server.mu.Lock()
+	server.watchErr = apierrors.NewServiceUnavailable("unavailable")
+	server.mu.Unlock()
+	server.nextWatcher(t).Stop()
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the watch to fail")
+	}
+	require.Error(t, w.Err())
+
+	_, err = Start(context.Background(), source, func(event watch.Event) error { return nil }, nil)
+	require.Error(t, err, "Expected the initial watch error to be returned")
+}
+
+func TestForResource(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "WidgetList"})
+	newWidget := func(name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("example.com/v1")
+		u.SetKind("Widget")
+		u.SetNamespace("ns1")
+		u.SetName(name)
+		return u
+	}
+	_, err := client.Resource(gvr).Namespace("ns1").Create(context.TODO(), newWidget("widget1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	names := make(chan string, 10)
+	w, err := Start(context.Background(), ForResource(client, gvr, "ns1"), func(event watch.Event) error {
+		names <- event.Object.(*unstructured.Unstructured).GetName()
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	defer w.Stop()
+	require.Equal(t, "widget1", <-names)
+
+	_, err = client.Resource(gvr).Namespace("ns1").Create(context.TODO(), newWidget("widget2"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "widget2", <-names)
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy computes the delay to wait before the next attempt of a task.
+type BackoffPolicy interface {
+	// Next returns the delay before the given retry attempt. attempt starts at 1
+	// for the first retry and prev is the delay returned by the previous call
+	// (zero for the first retry).
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// ConstantBackoff returns a policy that always waits for the given interval
+// between attempts. This is the behavior of DoRetryWithTimeout.
+func ConstantBackoff(interval time.Duration) BackoffPolicy {
+	return &constantBackoff{interval: interval}
+}
+
+type constantBackoff struct {
+	interval time.Duration
+}
+
+func (b *constantBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	return b.interval
+}
+
+// ExponentialBackoff returns a policy that starts with the initial delay and
+// multiplies it by factor after every attempt, never exceeding maxDelay. A
+// factor less than or equal to 1 defaults to 2. A non-positive maxDelay means
+// the delay is not capped.
+func ExponentialBackoff(initial, maxDelay time.Duration, factor float64) BackoffPolicy {
+	if factor <= 1 {
+		factor = 2
+	}
+	return &exponentialBackoff{initial: initial, maxDelay: maxDelay, factor: factor}
+}
+
+type exponentialBackoff struct {
+	initial  time.Duration
+	maxDelay time.Duration
+	factor   float64
+}
+
+func (b *exponentialBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := float64(b.initial) * math.Pow(b.factor, float64(attempt-1))
+	if delay >= math.MaxInt64 {
+		return capDelay(time.Duration(math.MaxInt64), b.maxDelay)
+	}
+	return capDelay(time.Duration(delay), b.maxDelay)
+}
+
+// DecorrelatedJitterBackoff returns a policy that picks a random delay between
+// base and three times the previous delay, never exceeding maxDelay. This
+// spreads out retries from many callers that started failing at the same time.
+// A non-positive maxDelay means the delay is not capped.
+func DecorrelatedJitterBackoff(base, maxDelay time.Duration) BackoffPolicy {
+	return &decorrelatedJitterBackoff{base: base, maxDelay: maxDelay}
+}
+
+type decorrelatedJitterBackoff struct {
+	base     time.Duration
+	maxDelay time.Duration
+}
+
+func (b *decorrelatedJitterBackoff) Next(attempt int, prev time.Duration) time.Duration {
+	if prev < b.base {
+		prev = b.base
+	}
+	upper := prev * 3
+	if upper <= b.base {
+		return capDelay(b.base, b.maxDelay)
+	}
+	delay := b.base + time.Duration(rand.Int63n(int64(upper-b.base)))
+	return capDelay(delay, b.maxDelay)
+}
+
+func capDelay(delay, maxDelay time.Duration) time.Duration {
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,124 @@
+// Package metrics exports Prometheus metrics about tasks retried with the task package.
+//
+// Usage:
+//
+//	collector := metrics.NewCollector()
+//	prometheus.MustRegister(collector)
+//	task.RegisterObserver(collector)
+package metrics
+
+import (
+	"time"
+
+	"github.com/portworx/sched-ops/task"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "sched_ops"
+	subsystem = "task"
+
+	operationLabel = "operation"
+	resultLabel    = "result"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Collector is a task.Observer that exports retry metrics as a prometheus.Collector.
+// All metrics are labeled with the operation name given to task.WithOperation.
+type Collector struct {
+	attempts        *prometheus.CounterVec
+	attemptDuration *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	taskDuration    *prometheus.HistogramVec
+	giveUps         *prometheus.CounterVec
+}
+
+var (
+	_ task.Observer        = &Collector{}
+	_ prometheus.Collector = &Collector{}
+)
+
+// NewCollector returns a new Collector. It needs to be registered with both a
+// prometheus.Registerer and task.RegisterObserver (or task.WithObserver).
+func NewCollector() *Collector {
+	return &Collector{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "attempts_total",
+			Help:      "Number of task attempts by operation and result.",
+		}, []string{operationLabel, resultLabel}),
+		attemptDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "attempt_duration_seconds",
+			Help:      "Duration of a single task attempt by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{operationLabel}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "retries_total",
+			Help:      "Number of failed task attempts that were retried, by operation.",
+		}, []string{operationLabel}),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "duration_seconds",
+			Help:      "Total duration of a task including all its retries, by operation and result.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+		}, []string{operationLabel, resultLabel}),
+		giveUps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "give_ups_total",
+			Help:      "Number of tasks that failed or timed out without succeeding, by operation.",
+		}, []string{operationLabel}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.attempts.Describe(ch)
+	c.attemptDuration.Describe(ch)
+	c.retries.Describe(ch)
+	c.taskDuration.Describe(ch)
+	c.giveUps.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.attempts.Collect(ch)
+	c.attemptDuration.Collect(ch)
+	c.retries.Collect(ch)
+	c.taskDuration.Collect(ch)
+	c.giveUps.Collect(ch)
+}
+
+// OnAttempt implements task.Observer.
+func (c *Collector) OnAttempt(operation string, attempt int, duration time.Duration, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	c.attempts.WithLabelValues(operation, result).Inc()
+	c.attemptDuration.WithLabelValues(operation).Observe(duration.Seconds())
+}
+
+// OnRetry implements task.Observer.
+func (c *Collector) OnRetry(operation string, attempt int, delay time.Duration, err error) {
+	c.retries.WithLabelValues(operation).Inc()
+}
+
+// OnSuccess implements task.Observer.
+func (c *Collector) OnSuccess(operation string, attempts int, elapsed time.Duration) {
+	c.taskDuration.WithLabelValues(operation, resultSuccess).Observe(elapsed.Seconds())
+}
+
+// OnGiveUp implements task.Observer.
+func (c *Collector) OnGiveUp(operation string, attempts int, elapsed time.Duration, err error) {
+	c.taskDuration.WithLabelValues(operation, resultFailure).Observe(elapsed.Seconds())
+	c.giveUps.WithLabelValues(operation).Inc()
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/portworx/sched-ops/task"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+
+	counter := 0
+	t1 := func() (interface{}, bool, error) {
+		counter++
+		if counter < 3 {
+			return nil, true, fmt.Errorf("task is failing")
+		}
+		return nil, false, nil
+	}
+	_, err := task.DoRetryWithTimeout(t1, time.Minute, time.Millisecond,
+		task.WithOperation("flaky"), task.WithObserver(collector))
+	require.NoError(t, err)
+
+	t2 := func() (interface{}, bool, error) {
+		return nil, false, fmt.Errorf("task failed")
+	}
+	_, err = task.DoRetryWithTimeout(t2, time.Minute, time.Millisecond,
+		task.WithOperation("broken"), task.WithObserver(collector))
+	require.Error(t, err)
+
+	require.Equal(t, float64(2), testutil.ToFloat64(collector.attempts.WithLabelValues("flaky", resultFailure)))
+	require.Equal(t, float64(1), testutil.ToFloat64(collector.attempts.WithLabelValues("flaky", resultSuccess)))
+	require.Equal(t, float64(2), testutil.ToFloat64(collector.retries.WithLabelValues("flaky")))
+	require.Equal(t, float64(0), testutil.ToFloat64(collector.giveUps.WithLabelValues("flaky")))
+	require.Equal(t, float64(1), testutil.ToFloat64(collector.giveUps.WithLabelValues("broken")))
+	require.Equal(t, 2, testutil.CollectAndCount(collector, "sched_ops_task_duration_seconds"))
+}
@@ -0,0 +1,110 @@
+package task
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultOperation is the operation name reported to observers when the caller
+// did not supply one with WithOperation.
+const DefaultOperation = "unknown"
+
+// Observer is notified about the progress of retried tasks. Implementations must be
+// safe for concurrent use as tasks may be retried from many goroutines at once.
+type Observer interface {
+	// OnAttempt is called after every completed attempt of the task. err is nil if the
+	// attempt succeeded.
+	OnAttempt(operation string, attempt int, duration time.Duration, err error)
+	// OnRetry is called when a failed attempt is going to be retried after the given delay.
+	OnRetry(operation string, attempt int, delay time.Duration, err error)
+	// OnSuccess is called when the task succeeded after the given number of attempts.
+	OnSuccess(operation string, attempts int, elapsed time.Duration)
+	// OnGiveUp is called when the task failed with a non-retryable error, or its context was
+	// done before it could succeed.
+	OnGiveUp(operation string, attempts int, elapsed time.Duration, err error)
+}
+
+// RetryOption customizes a single call to DoRetryWithContext or DoRetryWithTimeout.
+type RetryOption func(*retryOptions)
+
+type retryOptions struct {
+	operation string
+	observers []Observer
+}
+
+// WithOperation sets the operation name reported to observers for the task.
+func WithOperation(name string) RetryOption {
+	return func(o *retryOptions) {
+		o.operation = name
+	}
+}
+
+// WithObserver adds an observer that is notified only about this task, in addition to
+// the observers registered with RegisterObserver.
+func WithObserver(observer Observer) RetryOption {
+	return func(o *retryOptions) {
+		if observer != nil {
+			o.observers = append(o.observers, observer)
+		}
+	}
+}
+
+var (
+	globalObserversLock sync.RWMutex
+	globalObservers     []Observer
+)
+
+// RegisterObserver registers an observer that is notified about every retried task.
+func RegisterObserver(observer Observer) {
+	if observer == nil {
+		return
+	}
+
+	globalObserversLock.Lock()
+	defer globalObserversLock.Unlock()
+	globalObservers = append(globalObservers, observer)
+}
+
+// ResetObservers removes all the observers registered with RegisterObserver.
+func ResetObservers() {
+	globalObserversLock.Lock()
+	defer globalObserversLock.Unlock()
+	globalObservers = nil
+}
+
+func newRetryOptions(opts []RetryOption) *retryOptions {
+	o := &retryOptions{operation: DefaultOperation}
+
+	globalObserversLock.RLock()
+	o.observers = append(o.observers, globalObservers...)
+	globalObserversLock.RUnlock()
+
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *retryOptions) onAttempt(attempt int, duration time.Duration, err error) {
+	for _, observer := range o.observers {
+		observer.OnAttempt(o.operation, attempt, duration, err)
+	}
+}
+
+func (o *retryOptions) onRetry(attempt int, delay time.Duration, err error) {
+	for _, observer := range o.observers {
+		observer.OnRetry(o.operation, attempt, delay, err)
+	}
+}
+
+func (o *retryOptions) onSuccess(attempts int, elapsed time.Duration) {
+	for _, observer := range o.observers {
+		observer.OnSuccess(o.operation, attempts, elapsed)
+	}
+}
+
+func (o *retryOptions) onGiveUp(attempts int, elapsed time.Duration, err error) {
+	for _, observer := range o.observers {
+		observer.OnGiveUp(o.operation, attempts, elapsed, err)
+	}
+}
@@ -0,0 +1,100 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ItemError is the error returned by RunParallel for a single failed item.
+type ItemError struct {
+	// Index is the index of the failed item in the items given to RunParallel
+	Index int
+	// Err is the error returned for the item
+	Err error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the error returned for the item.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is returned by RunParallel when one or more items failed. It works with
+// errors.Is and errors.As, which match against the error of every failed item.
+type MultiError struct {
+	// Errors are the errors of the failed items ordered by item index
+	Errors []*ItemError
+}
+
+func (e *MultiError) Error() string {
+	errs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err.Err.Error())
+	}
+	return fmt.Sprintf("%d of the tasks failed:\n%s", len(e.Errors), strings.Join(errs, "\n"))
+}
+
+// Unwrap returns the errors of all the failed items.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// RunParallel calls fn for every item with at most concurrency calls running at the same time.
+// A concurrency less than 1 runs all the items at once. The returned results are in the same
+// order as the items. All the items are processed even if some of them fail; the failures are
+// returned as a *MultiError. Items that were not started before ctx is done fail with the
+// context error.
+func RunParallel[I any, R any](ctx context.Context, items []I, concurrency int, fn func(ctx context.Context, item I) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	if concurrency < 1 || concurrency > len(items) {
+		concurrency = len(items)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := range items {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = fn(ctx, items[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var multiErr *MultiError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if multiErr == nil {
+			multiErr = &MultiError{}
+		}
+		multiErr.Errors = append(multiErr.Errors, &ItemError{Index: i, Err: err})
+	}
+	if multiErr != nil {
+		return results, multiErr
+	}
+	return results, nil
+}
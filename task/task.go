@@ -8,75 +8,144 @@ import (
 	"time"
 )
 
-//TODO: export the type: type Task func() (string, error)
+// AttemptRecord describes a single failed attempt of a retried task.
+type AttemptRecord struct {
+	// Start is the time at which the attempt started
+	Start time.Time
+	// Duration is how long the attempt took
+	Duration time.Duration
+	// Err is the error returned by the attempt
+	Err error
+}
 
 // ErrTimedOut is returned when an operation times out
-// Is this type used anywhere? If not we can get rid off it in favor context.DeadlineExceeded
 type ErrTimedOut struct {
 	// Reason is the reason for the timeout
+	//
+	// Deprecated: use Attempts, Reason only joins the errors of the attempts.
 	Reason string
+	// Attempts are the failed attempts made before the timeout, oldest first
+	Attempts []AttemptRecord
 }
 
 func (e *ErrTimedOut) Error() string {
 	errString := "timed out performing task."
-	if len(e.Reason) > 0 {
+	if len(e.Attempts) > 0 {
+		errs := make([]string, 0, len(e.Attempts))
+		for _, a := range e.Attempts {
+			errs = append(errs, a.Err.Error())
+		}
+		errString = fmt.Sprintf("%s Errors generated in %d retries: {%s}", errString, len(e.Attempts), strings.Join(errs, "}\n{"))
+	} else if len(e.Reason) > 0 {
 		errString = fmt.Sprintf("%s, Error was: %s", errString, e.Reason)
 	}
 
 	return errString
 }
 
+// Unwrap allows errors.Is(err, context.DeadlineExceeded) to match a timed out task.
+func (e *ErrTimedOut) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
 // DoRetryWithTimeout performs given task with given timeout and timeBeforeRetry
-// TODO(stgleb): In future I would like to add context as a first param to this function
-// so calling code can cancel task.
-func DoRetryWithTimeout(t func() (interface{}, bool, error), timeout, timeBeforeRetry time.Duration) (interface{}, error) {
-	// Use context.Context as a standard go way of timeout and cancellation propagation amount goroutines.
+func DoRetryWithTimeout(t func() (interface{}, bool, error), timeout, timeBeforeRetry time.Duration, opts ...RetryOption) (interface{}, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	resultChan := make(chan interface{})
-	errChan := make(chan error)
-	errInRetires := make([]string, 0)
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-
-				if ctx.Err() != nil {
-					errChan <- ctx.Err()
-				}
-
-				return
-			default:
-				out, retry, err := t()
-				if err != nil {
-					if retry {
-						errInRetires = append(errInRetires, err.Error())
-						log.Printf("DoRetryWithTimeout - Error: {%v}, Next try in [%v], timeout [%v]", err, timeBeforeRetry, timeout)
-						time.Sleep(timeBeforeRetry)
-					} else {
-						errChan <- err
-						return
-					}
-				} else {
-					resultChan <- out
-					return
-				}
-			}
+	return DoRetryWithContext(ctx, t, ConstantBackoff(timeBeforeRetry), opts...)
+}
+
+// DoRetryWithContext performs the given task until it succeeds, returns a non-retryable
+// error or the given context is done. The delay between attempts is computed by the given
+// backoff policy. If the context deadline is exceeded an *ErrTimedOut is returned, if the
+// context is cancelled the context error is returned. A task that may block should observe
+// ctx itself, so that an attempt still in flight when the context is done returns as well.
+func DoRetryWithContext[T any](ctx context.Context, t func() (T, bool, error), policy BackoffPolicy, opts ...RetryOption) (T, error) {
+	var zero T
+	if policy == nil {
+		return zero, fmt.Errorf("backoff policy must not be nil")
+	}
+
+	type result struct {
+		out   T
+		retry bool
+		err   error
+	}
+
+	o := newRetryOptions(opts)
+	begin := time.Now()
+	attempts := make([]AttemptRecord, 0)
+	giveUp := func(attempt int, err error) (T, error) {
+		o.onGiveUp(attempt, time.Since(begin), err)
+		return zero, err
+	}
+
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		if ctx.Err() != nil {
+			return giveUp(attempt-1, retryContextError(ctx, attempts))
 		}
-	}()
-
-	select {
-	case result := <-resultChan:
-		return result, nil
-	case err := <-errChan:
-		if err == context.DeadlineExceeded {
-			return nil, &ErrTimedOut{
-				Reason: fmt.Sprintf("DoRetryWithTimeout timed out. Errors generated in retries: {%s}", strings.Join(errInRetires, "}\n{")),
-			}
+
+		// The attempt runs in its own goroutine so that a blocked task does not delay returning
+		// once the context is done. The channel is buffered so that the goroutine can always
+		// deliver its result and exit, even when nobody is waiting for it anymore.
+		start := time.Now()
+		resultChan := make(chan result, 1)
+		go func() {
+			out, retry, err := t()
+			resultChan <- result{out: out, retry: retry, err: err}
+		}()
+
+		var res result
+		select {
+		case <-ctx.Done():
+			return giveUp(attempt, retryContextError(ctx, attempts))
+		case res = <-resultChan:
+		}
+
+		o.onAttempt(attempt, time.Since(start), res.err)
+		if res.err == nil {
+			o.onSuccess(attempt, time.Since(begin))
+			return res.out, nil
+		}
+		if !res.retry {
+			return giveUp(attempt, res.err)
+		}
+
+		attempts = append(attempts, AttemptRecord{
+			Start:    start,
+			Duration: time.Since(start),
+			Err:      res.err,
+		})
+		delay = policy.Next(attempt, delay)
+		o.onRetry(attempt, delay, res.err)
+		if o.operation != DefaultOperation {
+			log.Printf("DoRetryWithContext - Operation: %s, Error: {%v}, Next try in [%v]", o.operation, res.err, delay)
+		} else {
+			log.Printf("DoRetryWithContext - Error: {%v}, Next try in [%v]", res.err, delay)
 		}
 
-		return nil, err
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return giveUp(attempt, retryContextError(ctx, attempts))
+		case <-timer.C:
+		}
+	}
+}
+
+func retryContextError(ctx context.Context, attempts []AttemptRecord) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		errs := make([]string, 0, len(attempts))
+		for _, a := range attempts {
+			errs = append(errs, a.Err.Error())
+		}
+		return &ErrTimedOut{
+			Reason:   fmt.Sprintf("DoRetryWithContext timed out. Errors generated in retries: {%s}", strings.Join(errs, "}\n{")),
+			Attempts: attempts,
+		}
 	}
+	return ctx.Err()
 }
@@ -1,7 +1,11 @@
 package task
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
@@ -68,3 +72,222 @@ func TestDoRetryWithTimeoutSuccessNilReturn(t *testing.T) {
 	require.NoError(t, err, "task must not fail")
 	require.Nil(t, output, "result must not  be nil")
 }
+
+func TestDoRetryWithContextTyped(t *testing.T) {
+	counter := 0
+	t1 := func() (int, bool, error) {
+		counter++
+		if counter < 3 {
+			return 0, true, fmt.Errorf("task is failing")
+		}
+		return counter, false, nil
+	}
+
+	output, err := DoRetryWithContext(context.Background(), t1, ConstantBackoff(10*time.Millisecond))
+	require.NoError(t, err, "task must not fail")
+	require.Equal(t, 3, output, "unexpected task output")
+}
+
+func TestDoRetryWithContextCancel(t *testing.T) {
+	t1 := func() (string, bool, error) {
+		return "", true, fmt.Errorf("task is failing")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := DoRetryWithContext(ctx, t1, ConstantBackoff(time.Minute))
+	require.ErrorIs(t, err, context.Canceled, "task should have been cancelled")
+	require.Less(t, time.Since(start), 10*time.Second, "task should return as soon as the context is cancelled")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = DoRetryWithContext(ctx, t1, ConstantBackoff(10*time.Millisecond))
+	var timedOut *ErrTimedOut
+	require.True(t, errors.As(err, &timedOut), "task should have timed out")
+}
+
+func TestBackoffPolicies(t *testing.T) {
+	constant := ConstantBackoff(time.Second)
+	require.Equal(t, time.Second, constant.Next(1, 0))
+	require.Equal(t, time.Second, constant.Next(5, time.Second))
+
+	exponential := ExponentialBackoff(100*time.Millisecond, time.Second, 2)
+	require.Equal(t, 100*time.Millisecond, exponential.Next(1, 0))
+	require.Equal(t, 200*time.Millisecond, exponential.Next(2, 0))
+	require.Equal(t, 800*time.Millisecond, exponential.Next(4, 0))
+	require.Equal(t, time.Second, exponential.Next(10, 0))
+	require.Equal(t, time.Second, exponential.Next(1000, 0))
+
+	jitter := DecorrelatedJitterBackoff(100*time.Millisecond, time.Second)
+	var delay time.Duration
+	for attempt := 1; attempt <= 50; attempt++ {
+		delay = jitter.Next(attempt, delay)
+		require.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		require.LessOrEqual(t, delay, time.Second)
+	}
+}
+
+func TestDoRetryWithTimeoutAttempts(t *testing.T) {
+	counter := 0
+	t1 := func() (interface{}, bool, error) {
+		counter++
+		return nil, true, fmt.Errorf("attempt %d failed", counter)
+	}
+
+	_, err := DoRetryWithTimeout(t1, 100*time.Millisecond, 30*time.Millisecond)
+	var timedOut *ErrTimedOut
+	require.True(t, errors.As(err, &timedOut), "task should have timed out")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotEmpty(t, timedOut.Attempts, "failed attempts should be recorded")
+	require.Contains(t, timedOut.Reason, "attempt 1 failed", "the deprecated reason should still be set")
+	for i, attempt := range timedOut.Attempts {
+		require.EqualError(t, attempt.Err, fmt.Sprintf("attempt %d failed", i+1))
+		require.False(t, attempt.Start.IsZero(), "attempt start time should be set")
+		if i > 0 {
+			require.True(t, attempt.Start.After(timedOut.Attempts[i-1].Start), "attempts should be ordered")
+		}
+	}
+}
+
+func TestDoRetryWithTimeoutNoLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		t1 := func() (interface{}, bool, error) {
+			time.Sleep(50 * time.Millisecond)
+			return nil, true, fmt.Errorf("task is failing")
+		}
+		_, err := DoRetryWithTimeout(t1, 10*time.Millisecond, time.Millisecond)
+		require.Error(t, err, "task was expected to time out")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), before, "timed out attempts should not leak goroutines")
+}
+
+type recordingObserver struct {
+	sync.Mutex
+	events []string
+}
+
+func (r *recordingObserver) record(event string) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingObserver) OnAttempt(operation string, attempt int, duration time.Duration, err error) {
+	r.record(fmt.Sprintf("%s attempt %d failed=%v", operation, attempt, err != nil))
+}
+
+func (r *recordingObserver) OnRetry(operation string, attempt int, delay time.Duration, err error) {
+	r.record(fmt.Sprintf("%s retry %d", operation, attempt))
+}
+
+func (r *recordingObserver) OnSuccess(operation string, attempts int, elapsed time.Duration) {
+	r.record(fmt.Sprintf("%s success %d", operation, attempts))
+}
+
+func (r *recordingObserver) OnGiveUp(operation string, attempts int, elapsed time.Duration, err error) {
+	r.record(fmt.Sprintf("%s give up %d", operation, attempts))
+}
+
+func TestDoRetryObservers(t *testing.T) {
+	global := &recordingObserver{}
+	RegisterObserver(global)
+	defer ResetObservers()
+
+	counter := 0
+	t1 := func() (interface{}, bool, error) {
+		counter++
+		if counter < 2 {
+			return nil, true, fmt.Errorf("task is failing")
+		}
+		return nil, false, nil
+	}
+
+	perCall := &recordingObserver{}
+	_, err := DoRetryWithTimeout(t1, time.Minute, time.Millisecond, WithOperation("op1"), WithObserver(perCall))
+	require.NoError(t, err)
+
+	expected := []string{
+		"op1 attempt 1 failed=true",
+		"op1 retry 1",
+		"op1 attempt 2 failed=false",
+		"op1 success 2",
+	}
+	require.Equal(t, expected, perCall.events)
+	require.Equal(t, expected, global.events)
+
+	t2 := func() (interface{}, bool, error) {
+		return nil, false, fmt.Errorf("task failed")
+	}
+	_, err = DoRetryWithTimeout(t2, time.Minute, time.Millisecond)
+	require.Error(t, err)
+	require.Equal(t, []string{
+		DefaultOperation + " attempt 1 failed=true",
+		DefaultOperation + " give up 1",
+	}, global.events[len(expected):])
+}
+
+var errOdd = errors.New("odd item")
+
+func TestRunParallel(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	var running, maxRunning int32
+	var lock sync.Mutex
+	results, err := RunParallel(context.Background(), items, 3, func(ctx context.Context, item int) (int, error) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if item%2 == 1 {
+			return 0, fmt.Errorf("item %d: %w", item, errOdd)
+		}
+		return item * 10, nil
+	})
+	require.LessOrEqual(t, maxRunning, int32(3), "concurrency should be bounded")
+	require.Equal(t, []int{0, 0, 20, 0, 40, 0, 60, 0}, results)
+
+	require.ErrorIs(t, err, errOdd)
+	var multiErr *MultiError
+	require.True(t, errors.As(err, &multiErr), "expected a multi error")
+	require.Len(t, multiErr.Errors, 4)
+	for i, itemErr := range multiErr.Errors {
+		require.Equal(t, 2*i+1, itemErr.Index)
+	}
+
+	results, err = RunParallel(context.Background(), items, 0, func(ctx context.Context, item int) (int, error) {
+		return item, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, items, results)
+}
+
+func TestRunParallelCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	called := false
+	_, err := RunParallel(ctx, []string{"a", "b"}, 1, func(ctx context.Context, item string) (string, error) {
+		called = true
+		return item, nil
+	})
+	require.False(t, called, "no item should run once the context is cancelled")
+	require.ErrorIs(t, err, context.Canceled)
+}